@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+const (
+	// backendCtypes generates python modules calling the shared libs with the gofunc ctypes decorator,
+	// backendCPython generates a C extension module per lib, calling its shared lib with the C API of python,
+	// and backendCffi generates a cffi build script per lib, whose extension module is called by python wrappers
+	backendCtypes  = "ctypes"
+	backendCPython = "cpython"
+	backendCffi    = "cffi"
+)
+
+// extSuffixes are the suffixes of the names of the extension modules of the backends, after the name of the shared lib
+var extSuffixes = map[string]string{
+	backendCPython: "_ext",
+	backendCffi:    "_cffi",
+}
+
+// extModule returns the name of the extension module of a lib with a backend, from the file name of its shared lib
+func extModule(sharedLib, backend string) string {
+	return strings.TrimSuffix(sharedLib, filepath.Ext(sharedLib)) + extSuffixes[backend]
+}
+
+// extSource returns the file name of the generated source of the extension module of a lib with a backend:
+// the C file of the cpython backend, or the build script of the cffi one
+func extSource(sharedLib, backend string) string {
+	if backend == backendCffi {
+		return extModule(sharedLib, backend) + "_build.py"
+	}
+	return extModule(sharedLib, backend) + ".c"
+}
+
+// libHeader returns the file name of the C header which go build writes next to the shared lib of a lib
+func libHeader(sharedLib string) string {
+	return strings.TrimSuffix(sharedLib, filepath.Ext(sharedLib)) + ".h"
+}
+
+// pythonConfig is the config of the python interpreter the C extension modules are built for
+type pythonConfig struct {
+	// Include is the dir of Python.h
+	Include string
+	// ExtSuffix is the suffix of the file names of the extension modules, e.g. .cpython-311-x86_64-linux-gnu.so
+	ExtSuffix string
+}
+
+// pythonConfig returns the config of the python interpreter of b
+func (b *buildOptions) pythonConfig() (*pythonConfig, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(b.Python, "-c", `import sysconfig; print(sysconfig.get_paths()["include"]); print(sysconfig.get_config_var("EXT_SUFFIX"))`)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if len(b.Env) > 0 {
+		cmd.Env = append(os.Environ(), b.Env...)
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Couldn't get the config of %s: %v: %s", b.Python, err, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || lines[1] == "None" {
+		return nil, fmt.Errorf("Couldn't get the config of %s: unexpected output %q", b.Python, stdout.String())
+	}
+	return &pythonConfig{Include: strings.TrimSpace(lines[0]), ExtSuffix: strings.TrimSpace(lines[1])}, nil
+}
+
+// hasExt returns true if the backend builds an extension module per lib
+func hasExt(backend string) bool {
+	return backend == backendCPython || backend == backendCffi
+}
+
+// extFile returns the file name of the extension module of a lib
+func (b *buildOptions) extFile(pyLib *libfunc.Lib) string {
+	return extModule(pyLib.SharedLib, b.Backend) + b.python.ExtSuffix
+}
+
+// cc returns the C compiler of the extension modules, CC as for cgo, cc by default
+func (b *buildOptions) cc() string {
+	cc := os.Getenv("CC")
+	for _, env := range b.Env {
+		if strings.HasPrefix(env, "CC=") {
+			cc = strings.TrimPrefix(env, "CC=")
+		}
+	}
+	if cc == "" {
+		return "cc"
+	}
+	return cc
+}
+
+// extLinkArgs returns the flags of cc linking the extension module of a lib on goos with its shared lib,
+// which is found next to it at run time. The shared libs aren't named lib<name>.so, so they're linked by file name
+// with GNU ld on linux, and by path on macOS, where the python symbols must be left undefined until the module is imported.
+func extLinkArgs(goos, sharedLib string) ([]string, error) {
+	switch goos {
+	case "linux":
+		return []string{"-L.", "-l:" + sharedLib, "-Wl,-rpath,$ORIGIN"}, nil
+	case "darwin":
+		return []string{"./" + sharedLib, "-Wl,-rpath,@loader_path", "-Wl,-undefined,dynamic_lookup"}, nil
+	}
+	return nil, fmt.Errorf("the extension modules can't be built on %s, only on linux and darwin", goos)
+}
+
+// buildExt builds the extension module of a lib, once its shared lib and its header are built:
+// the C file is compiled with the cpython backend, and the build script is run by python with the cffi one.
+// The extension module is linked with the shared lib, which is found next to it.
+func buildExt(out io.Writer, dir string, pyLib *libfunc.Lib, b *buildOptions) error {
+	args := []string{b.Python, extSource(pyLib.SharedLib, b.Backend)}
+	if b.Backend == backendCPython {
+		linkArgs, err := extLinkArgs(runtime.GOOS, pyLib.SharedLib)
+		if err != nil {
+			return err
+		}
+		args = append(strings.Fields(b.cc()), "-shared", "-fPIC", "-O2", "-I"+b.python.Include, "-I.",
+			"-o", b.extFile(pyLib), extSource(pyLib.SharedLib, b.Backend))
+		args = append(args, linkArgs...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stderr = out
+	cmd.Stdout = out
+	if len(b.Env) > 0 {
+		cmd.Env = append(os.Environ(), b.Env...)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Couldn't build %s in %s: %v", b.extFile(pyLib), dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_extLinkArgs(t *testing.T) {
+	tests := []struct {
+		GOOS string
+		Args []string
+		Err  string
+	}{
+		{"linux", []string{"-L.", "-l:_lib.so", "-Wl,-rpath,$ORIGIN"}, ""},
+		{"darwin", []string{"./_lib.so", "-Wl,-rpath,@loader_path", "-Wl,-undefined,dynamic_lookup"}, ""},
+		{"windows", nil, "the extension modules can't be built on windows, only on linux and darwin"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			args, err := extLinkArgs(test.GOOS, "_lib.so")
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if fmt.Sprint(args) != fmt.Sprint(test.Args) || errStr != test.Err {
+				t.Fatalf("match should be %v %q, was %v %q", test.Args, test.Err, args, errStr)
+			}
+		})
+	}
+}
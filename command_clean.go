@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/ast"
+)
+
+// pycacheDir is the dir in which python caches the bytecode of the modules of a dir
+const pycacheDir = "__pycache__"
+
+func runClean(args []string) int {
+	o := &options{}
+	flags := o.flagSet("clean", "Remove the files generated in the output dir of the packages, and the dir itself if it's empty then.")
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	pkgs, err := ast.FindPackages(pwd, patterns, o.Tags, o.Build.Go, o.Build.Env)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	for _, pkg := range pkgs {
+		mod, err := ast.ParseMod(pkg.Dir)
+		if err != nil {
+			printError(err)
+			return 1
+		}
+
+		data := newLayoutData(pkg.Name, pkg.ImportPath, pkg.Dir, mod)
+		outDir, err := o.outDir(data)
+		if err != nil {
+			printError(err)
+			return 1
+		}
+		sharedLib, _, err := o.names(data)
+		if err != nil {
+			printError(err)
+			return 1
+		}
+
+		if err := cleanDir(outDir, sharedLib); err != nil {
+			printError(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// cleanDir removes the files generated by pygo in dir, and dir if it's empty then.
+// Other files are left untouched.
+func cleanDir(dir, sharedLib string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the go.sum of a generated go.mod is copied from the module
+	goMod := filepath.Join(dir, "go.mod")
+	generatedGoMod, err := hasPygoHeader(goMod)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	remaining := 0
+	// the python modules removed, whose bytecode is removed from __pycache__ then
+	modules := map[string]bool{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() && entry.Name() == pycacheDir {
+			continue
+		}
+		generated, err := isGenerated(path, entry, sharedLib)
+		if err != nil {
+			return err
+		}
+		if entry.Name() == "go.sum" {
+			generated = generatedGoMod
+		}
+		if !generated {
+			log.Printf("[DEBUG] %s is kept, it's not generated by pygo", path)
+			remaining++
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		log.Printf("[INFO] %s removed", path)
+		if filepath.Ext(entry.Name()) == ".py" {
+			modules[strings.TrimSuffix(entry.Name(), ".py")] = true
+		}
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() != pycacheDir {
+			continue
+		}
+		removed, err := cleanPycache(filepath.Join(dir, entry.Name()), modules)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			remaining++
+		}
+	}
+
+	if remaining == 0 {
+		log.Printf("[INFO] %s removed", dir)
+		return os.Remove(dir)
+	}
+	return nil
+}
+
+// cleanPycache removes the bytecode of the given python modules from a __pycache__ dir,
+// e.g. lib.cpython-311.pyc, and the dir if it's empty then. It returns true if the dir is removed.
+func cleanPycache(dir string, modules map[string]bool) (bool, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	remaining := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		module := strings.SplitN(entry.Name(), ".", 2)[0]
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pyc" || !modules[module] {
+			log.Printf("[DEBUG] %s is kept, it's not the bytecode of a python module generated by pygo", path)
+			remaining++
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return false, err
+		}
+	}
+
+	if remaining > 0 {
+		return false, nil
+	}
+	log.Printf("[INFO] %s removed", dir)
+	return true, os.Remove(dir)
+}
+
+// isGenerated returns true if the file is generated by pygo, or by building the generated files.
+// Only the files with the header of pygo are considered generated, not the ones of other generators.
+func isGenerated(path string, info os.FileInfo, sharedLib string) (bool, error) {
+	name := info.Name()
+	if info.IsDir() {
+		return false, nil
+	}
+
+	switch ext := filepath.Ext(name); ext {
+	case ".so", ".a":
+		// the extension module of a lib is named after its shared lib, with the suffix of the python interpreter
+		return name == sharedLib || strings.HasPrefix(name, extModule(sharedLib, backendCPython)+".") ||
+			strings.HasPrefix(name, extModule(sharedLib, backendCffi)+"."), nil
+	case ".h", ldflagsExt:
+		// the header of a lib, and the linker flags of a static archive, are named after it
+		return strings.TrimSuffix(name, ext) == strings.TrimSuffix(sharedLib, filepath.Ext(sharedLib)), nil
+	case stampExt:
+		return strings.TrimSuffix(name, ext) == sharedLib, nil
+	case ".go", ".py", ".pyi", ".typed", ".mod", ".c":
+		return hasPygoHeader(path)
+	}
+	return false, nil
+}
+
+// firstLine returns the first line of a file, empty if the file is empty
+func firstLine(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+	return scanner.Text(), nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestMain_cleanDir(t *testing.T) {
+	tests := []struct {
+		SharedLib string
+		Files     map[string]string
+		Remaining []string
+	}{
+		{
+			"_lib.so",
+			map[string]string{
+				"lib.go":                                "// Code generated by pygo; DO NOT EDIT.\npackage main\n",
+				"lib.py":                                "# Code generated by pygo; DO NOT EDIT.\n",
+				"lib.pyi":                               "# Code generated by pygo; DO NOT EDIT.\n",
+				"py.typed":                              "# Code generated by pygo; DO NOT EDIT.\n",
+				"_lib.so":                               "",
+				"_lib.h":                                "",
+				"_lib.so.stamp":                         "",
+				"__pycache__/lib.cpython-311.pyc":       "",
+				"__pycache__/lib.cpython-311.opt-1.pyc": "",
+				"go.mod":                                "// Code generated by pygo; DO NOT EDIT.\nmodule m/pygo\n",
+				"go.sum":                                "",
+			},
+			nil,
+		},
+		{
+			"_lib.so",
+			map[string]string{
+				"lib.go":       "// Code generated by pygo; DO NOT EDIT.\npackage main\n",
+				"mine.go":      "package main\n",
+				"mine.py":      "# Code generated by hand\n",
+				"notes.txt":    "",
+				"lib.so":       "",
+				"lib.so.stamp": "",
+				"go.sum":       "",
+			},
+			[]string{"go.sum", "lib.so", "lib.so.stamp", "mine.go", "mine.py", "notes.txt"},
+		},
+		{
+			"liblib.a",
+			map[string]string{
+				"lib.go":         "// Code generated by pygo; DO NOT EDIT.\npackage main\n",
+				"liblib.a":       "",
+				"liblib.h":       "",
+				"liblib.ldflags": "-lpthread\n",
+				"liblib.a.stamp": "",
+				"_lib.so":        "",
+				"other.a":        "",
+			},
+			[]string{"_lib.so", "other.a"},
+		},
+		{
+			"_lib.so",
+			map[string]string{
+				"lib.go":     "// Code generated by pygo; DO NOT EDIT.\npackage main\n",
+				"_lib_ext.c": "// Code generated by pygo; DO NOT EDIT.\n",
+				"_lib_ext.cpython-311-x86_64-linux-gnu.so":  "",
+				"_lib_cffi_build.py":                        "# Code generated by pygo; DO NOT EDIT.\n",
+				"_lib_cffi.cpython-311-x86_64-linux-gnu.so": "",
+				"_lib.so":       "",
+				"mine.c":        "int mine;\n",
+				"_lib_other.so": "",
+			},
+			[]string{"_lib_other.so", "mine.c"},
+		},
+		{
+			// with -o ., the files of other generators and the bytecode of other modules are kept
+			"_lib.so",
+			map[string]string{
+				"lib.py":                           "# Code generated by pygo; DO NOT EDIT.\n",
+				"lib_string.go":                    "// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n",
+				"api.pb.go":                        "// Code generated by protoc-gen-go. DO NOT EDIT.\n",
+				"gen.go":                           "// Code generated by go generate; DO NOT EDIT.\n",
+				"mine.py":                          "",
+				"__pycache__/lib.cpython-311.pyc":  "",
+				"__pycache__/mine.cpython-311.pyc": "",
+			},
+			[]string{"__pycache__", "api.pb.go", "gen.go", "lib_string.go", "mine.py"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pygo")
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			outDir := filepath.Join(dir, "pygo")
+			for name, content := range test.Files {
+				path := filepath.Join(outDir, name)
+				if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+					t.Fatalf("%v", err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}
+
+			if err := cleanDir(outDir, test.SharedLib); err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			entries, err := ioutil.ReadDir(outDir)
+			if test.Remaining == nil {
+				if !os.IsNotExist(err) {
+					t.Fatalf("dir %s should have been removed, was %v", outDir, entries)
+				}
+				return
+			}
+
+			remaining := []string{}
+			for _, entry := range entries {
+				remaining = append(remaining, entry.Name())
+			}
+			sort.Strings(remaining)
+			if fmt.Sprint(remaining) != fmt.Sprint(test.Remaining) {
+				t.Fatalf("match should be %v, was %v", test.Remaining, remaining)
+			}
+		})
+	}
+}
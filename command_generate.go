@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func runGenerate(args []string) int {
+	o := &options{}
+	flags := o.generateFlagSet("generate", "Generate the go and python files of the packages, without building the shared libraries.")
+	o.dryRunFlag(flags)
+	check := flags.Bool("check", false, "don't write the files, print their diff and fail if they're not up to date, e.g. in CI")
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+
+	if *check || o.DryRun {
+		return previewPkgs(o, patterns, *check)
+	}
+	_, code = generatePkgs(o, patterns)
+	return code
+}
+
+func runBuild(args []string) int {
+	o := &options{}
+	flags := o.generateFlagSet("build", "Generate the go and python files of the packages, and build their shared libraries.")
+	o.dryRunFlag(flags)
+	o.buildFlags(flags)
+	force := flags.Bool("force", false, "build the shared libraries, even if their build stamp shows they're up to date")
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+	if o.DryRun {
+		return previewPkgs(o, patterns, false)
+	}
+
+	pyPkgs, code := generatePkgs(o, patterns)
+	if code != 0 || len(pyPkgs) == 0 {
+		return code
+	}
+
+	if err := build(pyPkgs, o.buildOptions(), *force, o.jobs()); err != nil {
+		printError(err)
+		return 1
+	}
+	return 0
+}
+
+// generatePkgs loads the packages matching patterns, and generates their files.
+// It returns the packages which have something to export, and the exit code of the command.
+func generatePkgs(o *options, patterns []string) ([]*pyPkg, int) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		printError(err)
+		return nil, 1
+	}
+
+	pyPkgs, diagnostics := loadPkgs(pwd, patterns, o)
+	printDiags(pwd, diagnostics)
+	if diagnostics.HasErrors() {
+		return nil, 1
+	}
+
+	// the files of the packages with nothing to export anymore are removed too
+	if err := generate(pyPkgs, o); err != nil {
+		printError(err)
+		return nil, 1
+	}
+
+	pyPkgs = exportedPkgs(pyPkgs)
+	if len(pyPkgs) == 0 {
+		log.Printf("[INFO] nothing to export in %v", patterns)
+	}
+	return pyPkgs, 0
+}
+
+// previewPkgs loads the packages matching patterns, and prints the changes of their generated files on stdout.
+// With check, it fails if the generated files are not up to date.
+func previewPkgs(o *options, patterns []string, check bool) int {
+	pwd, err := os.Getwd()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	pyPkgs, diagnostics := loadPkgs(pwd, patterns, o)
+	printDiags(pwd, diagnostics)
+	if diagnostics.HasErrors() {
+		return 1
+	}
+
+	changes, err := plan(pyPkgs, o)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	printChanges(os.Stdout, pwd, changes)
+
+	if len(changes) == 0 {
+		log.Printf("[INFO] the generated files of %v are up to date", patterns)
+		return 0
+	}
+	if check {
+		printError(fmt.Errorf("the generated files are not up to date, %d changes, run pygo generate", len(changes)))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func runInspect(args []string) int {
+	o := &options{}
+	asJSON := false
+	flags := o.flagSet("inspect", "Show the python API generated from the packages, without generating anything.\nWith -json, print it as a JSON manifest, including the funcs and structs which aren't exported and why.")
+	flags.BoolVar(&asJSON, "json", false, "print the API as a JSON manifest, whose format is stable")
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	pyPkgs, diagnostics := loadPkgs(pwd, patterns, o)
+	printDiags(pwd, diagnostics)
+	if diagnostics.HasErrors() {
+		return 1
+	}
+
+	if asJSON {
+		if err := writeManifest(os.Stdout, pyPkgs, o, pwd); err != nil {
+			printError(err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, p := range exportedPkgs(pyPkgs) {
+		outDir := p.OutDir
+		if rel, err := filepath.Rel(pwd, outDir); err == nil {
+			outDir = rel
+		}
+		printAPI(os.Stdout, p, outDir)
+	}
+	return 0
+}
+
+// printAPI prints the python modules of a package, with the go types of their funcs
+func printAPI(w io.Writer, p *pyPkg, outDir string) {
+	fmt.Fprintf(w, "%s (%s)\n", p.ImportPath, outDir)
+	for _, pyLib := range p.Libs {
+		for _, module := range pyLib.Modules() {
+			m := pyLib.Module(module)
+			fmt.Fprintf(w, "  module %s (%s)\n", module, pyLib.SharedLib)
+
+			for _, s := range m.Structs {
+				fields := make([]string, len(s.Fields))
+				for i, f := range s.Fields {
+					fields[i] = fmt.Sprintf("%s: %s", f.PyName, f.Type)
+					if f.ReadOnly {
+						fields[i] += " (readonly)"
+					}
+				}
+				fmt.Fprintf(w, "    class %s(%s)%s\n", s.PyName, strings.Join(fields, ", "), goName(s.PyName, s.Name))
+			}
+
+			for _, f := range m.Funcs {
+				args := make([]string, len(f.Args))
+				for i, arg := range f.Args {
+					args[i] = fmt.Sprintf("%s: %s", arg.PyName(), arg.Type)
+					if arg.Default != "" {
+						args[i] = fmt.Sprintf("%s = %s", args[i], arg.Default)
+					}
+				}
+				result := ""
+				if !f.IsVoid() {
+					result = fmt.Sprintf(" -> %s", f.Result)
+				}
+				fmt.Fprintf(w, "    def %s(%s)%s%s\n", f.PyName, strings.Join(args, ", "), result, funcNotes(f))
+			}
+		}
+	}
+}
+
+// goName returns a note with the go name, if it differs from the python one
+func goName(pyName, name string) string {
+	if pyName == name {
+		return ""
+	}
+	return fmt.Sprintf("  # go: %s", name)
+}
+
+func funcNotes(f *libfunc.Func) string {
+	notes := []string{}
+	if f.PyName != f.Name {
+		notes = append(notes, fmt.Sprintf("go: %s", f.Name))
+	}
+	if f.HoldGil {
+		notes = append(notes, "holds the GIL")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  # %s", strings.Join(notes, ", "))
+}
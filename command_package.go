@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// pyprojectFile is the file of the python package laid out by pygo package, which describes it
+	pyprojectFile = "pyproject.toml"
+	// pygoRequirement is the python package of the runtime of pygo, which the generated python modules import
+	pygoRequirement = "pygo"
+)
+
+// versionRe matches the versions of python packages, e.g. 1.2.0, 1.2.0rc1 or 1.2.0.dev3+g1234
+var versionRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*((a|b|rc)[0-9]+)?(\.post[0-9]+)?(\.dev[0-9]+)?(\+[A-Za-z0-9]+(\.[A-Za-z0-9]+)*)?$`)
+
+// packageFile is a built file installed in the python package
+type packageFile struct {
+	// Src is the path of the file in the output dir of its package
+	Src string
+	// Name is its name in the python package
+	Name string
+}
+
+// packageOptions are the flags of pygo package
+type packageOptions struct {
+	// Name and Version are the name and the version of the python package
+	Name    string
+	Version string
+	// Dist is the dir in which the python package and its wheel are written
+	Dist string
+	// PlatName is the platform tag of the wheel, which defaults to the one of the python interpreter
+	PlatName string
+}
+
+// flags adds the flags of pygo package to flags
+func (p *packageOptions) flags(flags *flag.FlagSet) {
+	flags.StringVar(&p.Name, "name", "", "the name of the python package, defaults to the last element of the path of the module")
+	flags.StringVar(&p.Version, "version", "0.0.0", "the version of the python package")
+	flags.StringVar(&p.Dist, "dist", "dist", "the dir in which the python package and its wheel are written")
+	flags.StringVar(&p.PlatName, "plat-name", "", "the platform tag of the wheel, e.g. manylinux2014_x86_64, defaults to the one of -python")
+}
+
+func runPackage(args []string) int {
+	o := &options{}
+	p := &packageOptions{}
+	flags := o.generateFlagSet("package", "Build the packages, lay out their python modules and shared libraries as a python package in -dist,\nwith its __init__.py and pyproject.toml, and build its wheel there.")
+	o.buildFlags(flags)
+	p.flags(flags)
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+	// the static archives are linked in the host process, they can't be installed with the python modules
+	if o.Mode == modeCArchive {
+		printError(fmt.Errorf("pygo package can't be used with -mode=%s", modeCArchive))
+		return 1
+	}
+
+	pyPkgs, code := generatePkgs(o, patterns)
+	if code != 0 {
+		return code
+	}
+	if len(pyPkgs) == 0 {
+		printError(fmt.Errorf("nothing to package in %v", patterns))
+		return 1
+	}
+
+	b := o.buildOptions()
+	if err := build(pyPkgs, b, false, o.jobs()); err != nil {
+		printError(err)
+		return 1
+	}
+
+	tpls, err := loadTemplates(o.Templates)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	timestamp := ""
+	if o.Timestamp {
+		timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	wheel, err := packagePkgs(pyPkgs, b, p, tpls, timestamp)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	fmt.Println(wheel)
+	return 0
+}
+
+// packageName returns the name of the python package, which defaults to the last element of the path of module.
+// The errors of a default name tell how to set another one.
+func packageName(name, module string) (string, error) {
+	hint := "set another one with -name"
+	if name == "" {
+		name = strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToLower(path.Base(module)))
+		hint = fmt.Sprintf("it's the default name of module %s, set another one with -name, or name in the package section of the config file", module)
+	}
+	if !pyModuleRe.MatchString(name) {
+		return "", fmt.Errorf("invalid python package name %q, it must be a python identifier, %s", name, hint)
+	}
+	// the generated python modules import the runtime of pygo
+	if name == pygoRequirement {
+		return "", fmt.Errorf("invalid python package name %q, it's the name of the runtime of pygo, %s", name, hint)
+	}
+	return name, nil
+}
+
+// packagePkgs lays out the python modules and the shared libs of the built packages as a python package,
+// in a <name>-<version> dir of the dist dir, and writes its wheel next to it. It returns the path of the wheel.
+func packagePkgs(pyPkgs []*pyPkg, b *buildOptions, p *packageOptions, tpls *template.Template, timestamp string) (string, error) {
+	name, err := packageName(p.Name, pyPkgs[0].Mod.Main)
+	if err != nil {
+		return "", err
+	}
+	if !versionRe.MatchString(p.Version) {
+		return "", fmt.Errorf("invalid version %q, it must be a python package version, e.g. 1.2.0", p.Version)
+	}
+	tag, err := b.wheelTag(p.PlatName)
+	if err != nil {
+		return "", err
+	}
+
+	files, modules, err := packageFiles(pyPkgs, b)
+	if err != nil {
+		return "", err
+	}
+	requires := []string{pygoRequirement}
+	if b.Backend == backendCffi {
+		requires = append(requires, "cffi")
+	}
+	data := &packageFileData{Timestamp: timestamp, Name: name, Version: p.Version, Tag: tag, Modules: modules, Requires: requires}
+
+	initPy, err := renderPackageFile(tpls, pyInitTemplate, "__init__.py", data)
+	if err != nil {
+		return "", err
+	}
+	pyproject, err := renderPackageFile(tpls, pyprojectTemplate, pyprojectFile, data)
+	if err != nil {
+		return "", err
+	}
+
+	// the package dir is laid out again from scratch, unless it's not generated by pygo
+	dir := filepath.Join(p.Dist, fmt.Sprintf("%s-%s", name, p.Version))
+	if _, err := os.Stat(dir); err == nil {
+		if ok, err := hasPygoHeader(filepath.Join(dir, pyprojectFile)); err != nil || !ok {
+			return "", fmt.Errorf("Couldn't lay out the python package in %s: the dir exists, and its %s isn't generated by pygo", dir, pyprojectFile)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return "", fmt.Errorf("Couldn't remove %s: %v", dir, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, name), os.ModePerm); err != nil {
+		return "", fmt.Errorf("Couldn't create dir %s: %v", dir, err)
+	}
+
+	wheelFiles := []*genFile{{Path: name + "/__init__.py", Content: initPy}}
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f.Src)
+		if err != nil {
+			return "", fmt.Errorf("Couldn't read file %s: %v", f.Src, err)
+		}
+		wheelFiles = append(wheelFiles, &genFile{Path: name + "/" + f.Name, Content: content})
+	}
+	for _, f := range append(wheelFiles, &genFile{Path: pyprojectFile, Content: pyproject}) {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := ioutil.WriteFile(path, f.Content, 0644); err != nil {
+			return "", fmt.Errorf("Couldn't write file %s: %v", path, err)
+		}
+	}
+
+	wheel := filepath.Join(p.Dist, wheelName(data))
+	if err := writeWheel(wheel, wheelFiles, data); err != nil {
+		return "", err
+	}
+	return wheel, nil
+}
+
+// packageFiles returns the files of the built packages which are installed in the python package:
+// their python modules and stubs, their shared libs and extension modules, and py.typed.
+// It also returns the names of the python modules.
+func packageFiles(pyPkgs []*pyPkg, b *buildOptions) ([]*packageFile, []string, error) {
+	files := []*packageFile{}
+	modules := []string{}
+	sources := map[string]string{}
+	add := func(dir, name string) error {
+		src := filepath.Join(dir, name)
+		if prev, ok := sources[name]; ok {
+			return fmt.Errorf("Couldn't package %s and %s, they have the same name in the python package", prev, src)
+		}
+		sources[name] = src
+		files = append(files, &packageFile{Src: src, Name: name})
+		return nil
+	}
+
+	for _, p := range pyPkgs {
+		for _, pyLib := range p.Libs {
+			for _, module := range pyLib.Modules() {
+				modules = append(modules, module)
+				for _, name := range []string{module + ".py", module + ".pyi"} {
+					if err := add(p.OutDir, name); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+			if err := add(p.OutDir, pyLib.SharedLib); err != nil {
+				return nil, nil, err
+			}
+			if hasExt(b.Backend) {
+				if err := add(p.OutDir, b.extFile(pyLib)); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	if err := add(pyPkgs[0].OutDir, pyTypedFile); err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(modules)
+	return files, modules, nil
+}
+
+// renderPackageFile renders a file of the python package
+func renderPackageFile(tpls *template.Template, name, file string, data *packageFileData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpls.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, fmt.Errorf("Couldn't generate %s: %v", file, err)
+	}
+	if err := checkHeader(&genFile{Path: file, Content: buf.Bytes()}); err != nil {
+		return nil, fmt.Errorf("Couldn't generate %s: %v", file, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func TestMain_versionRe(t *testing.T) {
+	tests := []struct {
+		Version string
+		Match   bool
+	}{
+		{"1.2.0", true},
+		{"1", true},
+		{"1.2.0rc1", true},
+		{"1.2.0.post1.dev3+g1234.dirty", true},
+		{"v1.2.0", false},
+		{"1.2.", false},
+		{"1.2.0-beta", false},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if match := versionRe.MatchString(test.Version); match != test.Match {
+				t.Fatalf("match should be %v, was %v", test.Match, match)
+			}
+		})
+	}
+}
+
+func TestMain_packageName(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Module string
+		Result string
+		Err    string
+	}{
+		{"", "github.com/x/My-Lib.go", "my_lib_go", ""},
+		{"mylib", "github.com/yanndegat/pygo", "mylib", ""},
+		{"", "github.com/yanndegat/pygo", "", `invalid python package name "pygo", it's the name of the runtime of pygo, it's the default name of module github.com/yanndegat/pygo, set another one with -name, or name in the package section of the config file`},
+		{"", "example.com/1lib", "", `invalid python package name "1lib", it must be a python identifier, it's the default name of module example.com/1lib, set another one with -name, or name in the package section of the config file`},
+		{"pygo", "github.com/x/lib", "", `invalid python package name "pygo", it's the name of the runtime of pygo, set another one with -name`},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			name, err := packageName(test.Name, test.Module)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if name != test.Result || errStr != test.Err {
+				t.Fatalf("match should be %q %q, was %q %q", test.Result, test.Err, name, errStr)
+			}
+		})
+	}
+}
+
+func TestMain_packageFiles(t *testing.T) {
+	newLib := func(name string, modules ...string) *libfunc.Lib {
+		funcs := []*libfunc.Func{}
+		for _, module := range modules {
+			funcs = append(funcs, &libfunc.Func{Name: "F", Module: module, Result: libfunc.TypeVoid})
+		}
+		lib := libfunc.NewLib(name, funcs, nil, "")
+		lib.SetNames(lib.SharedLib, name)
+		return lib
+	}
+
+	b := &buildOptions{Backend: backendCPython, python: &pythonConfig{ExtSuffix: ".abi3.so"}}
+	pyPkgs := []*pyPkg{
+		{OutDir: "/a/pygo", Libs: []*libfunc.Lib{newLib("lib", "lib", "extra")}},
+		{OutDir: "/b/pygo", Libs: []*libfunc.Lib{newLib("other", "other")}},
+	}
+	files, modules, err := packageFiles(pyPkgs, b)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := []*packageFile{
+		{Src: "/a/pygo/lib.py", Name: "lib.py"},
+		{Src: "/a/pygo/lib.pyi", Name: "lib.pyi"},
+		{Src: "/a/pygo/extra.py", Name: "extra.py"},
+		{Src: "/a/pygo/extra.pyi", Name: "extra.pyi"},
+		{Src: "/a/pygo/_lib.so", Name: "_lib.so"},
+		{Src: "/a/pygo/_lib_ext.abi3.so", Name: "_lib_ext.abi3.so"},
+		{Src: "/b/pygo/other.py", Name: "other.py"},
+		{Src: "/b/pygo/other.pyi", Name: "other.pyi"},
+		{Src: "/b/pygo/_other.so", Name: "_other.so"},
+		{Src: "/b/pygo/_other_ext.abi3.so", Name: "_other_ext.abi3.so"},
+		{Src: "/a/pygo/py.typed", Name: "py.typed"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("match should be %v, was %v", expected, files)
+	}
+	if expected := []string{"extra", "lib", "other"}; !reflect.DeepEqual(modules, expected) {
+		t.Fatalf("match should be %v, was %v", expected, modules)
+	}
+
+	// the python modules of the packages are installed in the same dir
+	pyPkgs = append(pyPkgs, &pyPkg{OutDir: "/c/pygo", Libs: []*libfunc.Lib{newLib("lib", "lib")}})
+	_, _, err = packageFiles(pyPkgs, b)
+	if expected := "Couldn't package /a/pygo/lib.py and /c/pygo/lib.py, they have the same name in the python package"; err == nil || err.Error() != expected {
+		t.Fatalf("match should be %q, was %v", expected, err)
+	}
+}
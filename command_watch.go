@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yanndegat/pygo/internal/ast"
+)
+
+func runWatch(args []string) int {
+	o := &options{}
+	flags := o.generateFlagSet("watch", "Generate the files and build the shared libraries of the packages,\nthen again each time their go files change, until interrupted.")
+	o.buildFlags(flags)
+	interval := flags.Duration("interval", time.Second, "the interval between two polls of the go files")
+	patterns, code, ok := o.parse(flags, args)
+	if !ok {
+		return code
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	w := &watcher{
+		Options:  o,
+		Dir:      pwd,
+		Patterns: patterns,
+	}
+	return w.Run(*interval)
+}
+
+// watcher polls the go files of the packages, and rebuilds the libs of the packages affected by their changes
+type watcher struct {
+	Options  *options
+	Dir      string
+	Patterns []string
+
+	// dirs are the dirs of the watched packages and of their local deps, and modFiles the go.mod and go.sum of their modules
+	dirs     []string
+	modFiles []string
+	// files are the watched files, with their state at the last poll
+	files map[string]fileState
+}
+
+// fileState is the state of a watched file, which changes when it's written
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Run builds all the packages, then rebuilds the affected ones at each change, until interrupted
+func (w *watcher) Run(interval time.Duration) int {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprintf(os.Stderr, "watching %v, press Ctrl+C to stop\n", w.Patterns)
+	w.rebuild(nil)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupt:
+			return 0
+		case <-ticker.C:
+			changed := changedFiles(w.files, w.poll())
+			if len(changed) == 0 {
+				continue
+			}
+			log.Printf("[DEBUG] changed files: %v", changed)
+			w.rebuild(changed)
+		}
+	}
+}
+
+// rebuild generates the files and builds the shared libs of the packages affected by the changed files,
+// or of all the packages if changed is nil. It prints a status line, and the diagnostics if any.
+func (w *watcher) rebuild(changed []string) {
+	start := time.Now()
+	o := w.Options
+
+	status := func(libs []string, err error) {
+		names := strings.Join(libs, ", ")
+		if names == "" {
+			names = "nothing to export"
+		}
+		result := "ok"
+		if err != nil {
+			result = "FAILED"
+		}
+		fmt.Fprintf(os.Stderr, "%s %s: %s (%v)\n", start.Format("15:04:05"), result, names, time.Since(start).Round(time.Millisecond))
+	}
+
+	pkgs, err := ast.ListPackages(w.Dir, w.Patterns, o.Tags, o.Build.Go, o.Build.Env)
+	if err != nil {
+		printError(fmt.Errorf("Couldn't list packages %v: %v", w.Patterns, err))
+		status(nil, err)
+		return
+	}
+
+	pkgs, w.dirs, w.modFiles, err = watchedDirs(pkgs, o)
+	// the files are watched again, even if the rebuild fails
+	w.files = w.poll()
+	if err != nil {
+		printError(err)
+		status(nil, err)
+		return
+	}
+	if changed != nil {
+		pkgs = affectedPkgs(pkgs, changed)
+	}
+	if len(pkgs) == 0 {
+		return
+	}
+	libs := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		libs[i] = pkg.Name
+	}
+
+	pyPkgs, diagnostics := loadAstPkgs(pkgs, o)
+	printDiags(w.Dir, diagnostics)
+	if diagnostics.HasErrors() {
+		status(libs, fmt.Errorf("%d errors", len(diagnostics)))
+		return
+	}
+	if err := generate(pyPkgs, o); err != nil {
+		printError(err)
+		status(libs, err)
+		return
+	}
+	pyPkgs = exportedPkgs(pyPkgs)
+	if err := build(pyPkgs, o.buildOptions(), false, o.jobs()); err != nil {
+		printError(err)
+		status(libs, err)
+		return
+	}
+
+	libs = []string{}
+	for _, p := range pyPkgs {
+		for _, pyLib := range p.Libs {
+			libs = append(libs, pyLib.Name)
+		}
+	}
+	status(libs, nil)
+}
+
+// watchedDirs returns the packages to watch, their dirs and the ones of their local deps,
+// and the go.mod and go.sum of their modules. The output dirs of the packages are ignored.
+func watchedDirs(pkgs []*ast.Package, o *options) ([]*ast.Package, []string, []string, error) {
+	outDirs := map[string]bool{}
+	modFiles := []string{}
+	mods := map[string]bool{}
+	for _, pkg := range pkgs {
+		mod, err := ast.ParseMod(pkg.Dir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		outDir, err := o.outDir(newLayoutData(pkg.Name, pkg.ImportPath, pkg.Dir, mod))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		outDirs[outDir] = true
+		if !mods[mod.GoMod] {
+			mods[mod.GoMod] = true
+			modFiles = append(modFiles, mod.GoMod, filepath.Join(mod.Dir, "go.sum"))
+		}
+	}
+
+	watched := []*ast.Package{}
+	dirs := []string{}
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		if outDirs[pkg.Dir] {
+			log.Printf("[DEBUG] %s is not watched, it's an output dir", pkg.Dir)
+			continue
+		}
+		watched = append(watched, pkg)
+		pkgDirs := []string{pkg.Dir}
+		for _, dir := range pkg.LocalDeps {
+			pkgDirs = append(pkgDirs, dir)
+		}
+		for _, dir := range pkgDirs {
+			if !seen[dir] && !outDirs[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return watched, dirs, modFiles, nil
+}
+
+// poll returns the current state of the watched files: the go files of the watched dirs,
+// except the test ones, and the go.mod and go.sum files. The files which don't exist are left out.
+func (w *watcher) poll() map[string]fileState {
+	files := map[string]fileState{}
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			files[path] = fileState{ModTime: info.ModTime(), Size: info.Size()}
+		}
+	}
+
+	for _, path := range w.modFiles {
+		stat(path)
+	}
+	for _, dir := range w.dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Printf("[DEBUG] Couldn't read dir %s: %v", dir, err)
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			files[filepath.Join(dir, name)] = fileState{ModTime: entry.ModTime(), Size: entry.Size()}
+		}
+	}
+	return files
+}
+
+// changedFiles returns the sorted files which were created, written or removed between two polls
+func changedFiles(prev, cur map[string]fileState) []string {
+	changed := []string{}
+	for path, state := range cur {
+		if prevState, ok := prev[path]; !ok || !prevState.ModTime.Equal(state.ModTime) || prevState.Size != state.Size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// affectedPkgs returns the packages affected by the changed files: the packages of the changed files,
+// and the packages which depend on them, even if they're not watched. A change of a go.mod or go.sum affects all the packages.
+func affectedPkgs(pkgs []*ast.Package, changed []string) []*ast.Package {
+	changedDirs := map[string]bool{}
+	for _, path := range changed {
+		switch filepath.Base(path) {
+		case "go.mod", "go.sum":
+			return pkgs
+		}
+		changedDirs[filepath.Dir(path)] = true
+	}
+
+	changedPkgs := map[string]bool{}
+	for _, pkg := range pkgs {
+		if changedDirs[pkg.Dir] {
+			changedPkgs[pkg.ImportPath] = true
+		}
+		for path, dir := range pkg.LocalDeps {
+			if changedDirs[dir] {
+				changedPkgs[path] = true
+			}
+		}
+	}
+
+	affected := []*ast.Package{}
+	for _, pkg := range pkgs {
+		isAffected := changedPkgs[pkg.ImportPath]
+		for _, dep := range pkg.Deps {
+			isAffected = isAffected || changedPkgs[dep]
+		}
+		if isAffected {
+			affected = append(affected, pkg)
+		}
+	}
+	return affected
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yanndegat/pygo/internal/ast"
+)
+
+func TestMain_changedFiles(t *testing.T) {
+	now := time.Now()
+	prev := map[string]fileState{
+		"/m/a.go": {ModTime: now, Size: 10},
+		"/m/b.go": {ModTime: now, Size: 10},
+		"/m/c.go": {ModTime: now, Size: 10},
+	}
+
+	tests := []struct {
+		Cur     map[string]fileState
+		Changed []string
+	}{
+		{
+			map[string]fileState{"/m/a.go": {ModTime: now, Size: 10}, "/m/b.go": {ModTime: now, Size: 10}, "/m/c.go": {ModTime: now, Size: 10}},
+			[]string{},
+		},
+		{
+			map[string]fileState{"/m/a.go": {ModTime: now.Add(time.Second), Size: 10}, "/m/b.go": {ModTime: now, Size: 11}, "/m/c.go": {ModTime: now, Size: 10}},
+			[]string{"/m/a.go", "/m/b.go"},
+		},
+		{
+			map[string]fileState{"/m/a.go": {ModTime: now, Size: 10}, "/m/b.go": {ModTime: now, Size: 10}, "/m/d.go": {ModTime: now, Size: 10}},
+			[]string{"/m/c.go", "/m/d.go"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			changed := changedFiles(prev, test.Cur)
+			if !reflect.DeepEqual(changed, test.Changed) {
+				t.Fatalf("match should be %v, was %v", test.Changed, changed)
+			}
+		})
+	}
+}
+
+func TestMain_affectedPkgs(t *testing.T) {
+	pkgs := []*ast.Package{
+		{Dir: "/m", ImportPath: "m", Deps: []string{"fmt", "m/internal/util", "m/model"}, LocalDeps: map[string]string{"m/internal/util": "/m/internal/util", "m/model": "/m/model"}},
+		{Dir: "/m/model", ImportPath: "m/model", Deps: []string{"fmt", "m/internal/util"}, LocalDeps: map[string]string{"m/internal/util": "/m/internal/util"}},
+		{Dir: "/m/other", ImportPath: "m/other", Deps: []string{"fmt"}},
+	}
+
+	tests := []struct {
+		Changed  []string
+		Affected []string
+	}{
+		{[]string{"/m/lib.go"}, []string{"m"}},
+		{[]string{"/m/model/model.go"}, []string{"m", "m/model"}},
+		{[]string{"/m/other/a.go", "/m/other/b.go"}, []string{"m/other"}},
+		// the deps which aren't watched packages affect the packages depending on them
+		{[]string{"/m/internal/util/util.go"}, []string{"m", "m/model"}},
+		{[]string{"/m/go.sum"}, []string{"m", "m/model", "m/other"}},
+		{[]string{"/elsewhere/a.go"}, []string{}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			affected := []string{}
+			for _, pkg := range affectedPkgs(pkgs, test.Changed) {
+				affected = append(affected, pkg.ImportPath)
+			}
+			if !reflect.DeepEqual(affected, test.Affected) {
+				t.Fatalf("match should be %v, was %v", test.Affected, affected)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+	"github.com/yanndegat/pygo/internal/logging"
+)
+
+// command is a pygo subcommand
+type command struct {
+	Name     string
+	Synopsis string
+	Run      func(args []string) int
+}
+
+// commands are initialized in init, as the help command refers to them
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{Name: "generate", Synopsis: "Generate the go and python files of the packages", Run: runGenerate},
+		{Name: "build", Synopsis: "Generate the files, and build the shared libraries (default)", Run: runBuild},
+		{Name: "watch", Synopsis: "Build the packages, then again each time their go files change", Run: runWatch},
+		{Name: "package", Synopsis: "Build the packages, and lay them out as a python package with its wheel", Run: runPackage},
+		{Name: "clean", Synopsis: "Remove the generated files", Run: runClean},
+		{Name: "inspect", Synopsis: "Show the python API generated from the packages", Run: runInspect},
+		{Name: "version", Synopsis: "Show the version of pygo", Run: runVersion},
+		{Name: "help", Synopsis: "Show this help", Run: runHelp},
+	}
+}
+
+// runCommand runs the command named by the first arg.
+// Without a command, pygo builds the packages, e.g. with `//go:generate pygo`.
+func runCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			return runHelp(args[1:])
+		}
+		for _, c := range commands {
+			if c.Name == args[0] {
+				return c.Run(args[1:])
+			}
+		}
+	}
+	return runBuild(args)
+}
+
+func runHelp(args []string) int {
+	printUsage(os.Stdout)
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, `Usage: pygo [command] [flags] [packages]
+
+pygo generates python bindings of the go funcs and structs annotated with @pygo.export.
+Without a command, pygo builds the packages of the current dir, e.g. with //go:generate pygo.
+
+Commands:
+`)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.Name, c.Synopsis)
+	}
+	fmt.Fprintf(w, "\nRun `pygo <command> -h` for the flags of a command.\n")
+}
+
+// options are the flags shared by the commands which scan packages
+type options struct {
+	Tags     []string
+	Output   string
+	SoName   string
+	PyName   string
+	BuildTag string
+	// Mode is the build mode of the libs, c-shared or c-archive
+	Mode string
+	// Backend is the way the python modules call the libs, ctypes or cpython
+	Backend  string
+	LogLevel string
+	Verbose  bool
+	// Timestamp adds the time of the generation to the generated files, which aren't reproducible anymore
+	Timestamp bool
+	// DryRun prints the changes of the generated files, without writing them
+	DryRun bool
+	// Jobs is the number of libs generated and built in parallel
+	Jobs int
+	// Templates is the dir of the templates overriding the default ones
+	Templates string
+	// Config is the path of the config file, which defaults to the pygo.json next to go.mod
+	Config string
+
+	// Build are the options of go vet and go build, except the tags
+	Build buildOptions
+
+	// the following options are only set by the config file
+	Packages   []string
+	Converters libfunc.Converters
+	Funcs      map[string]*funcConfig
+	// configPath is the path of the config file which was applied, if any
+	configPath string
+}
+
+// flagSet returns the flags of a command, usage being printed with -h
+func (o *options) flagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Var((*tagsFlag)(&o.Tags), "tags", "a comma-separated list of build tags to consider satisfied")
+	flags.StringVar(&o.Output, "o", defaultOutput, "the template of the dir in which the files are generated, relative to each package")
+	flags.StringVar(&o.SoName, "so-name", defaultSoName, "the template of the name of the shared lib, without the .so extension")
+	flags.StringVar(&o.PyName, "py-name", defaultPyName, "the template of the name of the main python module of a lib")
+	flags.StringVar(&o.Mode, "mode", modeCShared, fmt.Sprintf("the build mode of the libs, %s, or %s to link them statically in the host process of python", modeCShared, modeCArchive))
+	flags.StringVar(&o.Backend, "backend", backendCtypes, fmt.Sprintf("the way the python modules call the libs, %s, %s to generate and build a C extension module per lib, or %s to build one with cffi", backendCtypes, backendCPython, backendCffi))
+	flags.StringVar(&o.BuildTag, "build-tag", "", "a build tag set on the generated go files, so that regular builds ignore them")
+	flags.StringVar(&o.LogLevel, "log-level", "", fmt.Sprintf("the log level, one of %v, overrides PYGO_LOG", logging.ValidLevels))
+	flags.BoolVar(&o.Verbose, "v", false, "print the progress, same as -log-level=INFO")
+	flags.StringVar(&o.Config, "config", "", "the config file, defaults to the "+configFile+" next to go.mod; the flags override it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: pygo %s [flags] [packages]\n\n%s\n\nPackages are go package patterns, such as ./..., and default to the current dir.\n\nFlags:\n", name, usage)
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "%s", templatesUsage)
+	}
+	return flags
+}
+
+// generateFlagSet returns the flags of a command which generates files
+func (o *options) generateFlagSet(name, usage string) *flag.FlagSet {
+	flags := o.flagSet(name, usage)
+	flags.BoolVar(&o.Timestamp, "timestamp", false, "add the time of the generation to the generated files")
+	flags.StringVar(&o.Templates, "templates", "", "a dir of *.tmpl files overriding the templates of the generated files, by name")
+	flags.IntVar(&o.Jobs, "j", runtime.NumCPU(), "the number of libs generated and built in parallel")
+	return flags
+}
+
+// dryRunFlag registers the -dry-run flag of the commands which generate files
+func (o *options) dryRunFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&o.DryRun, "dry-run", false, "print the files which would be created, modified or deleted and their diff, without writing or building anything")
+}
+
+const templatesUsage = `
+The templates of -o, -so-name and -py-name are go templates, e.g. -o "{{.ModDir}}/../build/{{.Pkg}}",
+with the following fields:
+  .Lib         the name of the go package
+  .ImportPath  the import path of the go package
+  .Dir         the dir of the go package
+  .Pkg         the dir of the go package, relative to the root of its module
+  .ModDir      the root dir of the module
+When the output dir is outside of the module, a go.mod is generated in it.
+`
+
+// buildFlags registers the flags of the commands which build the shared libs
+func (o *options) buildFlags(flags *flag.FlagSet) {
+	flags.StringVar(&o.Build.Go, "go", "go", "the go binary which vets and builds the shared libs")
+	flags.StringVar(&o.Build.LDFlags, "ldflags", "", `the -ldflags of go build, e.g. "-s -w" to strip the shared libs`)
+	flags.StringVar(&o.Build.GCFlags, "gcflags", "", `the -gcflags of go build, e.g. "all=-N -l" to debug the shared libs`)
+	flags.BoolVar(&o.Build.TrimPath, "trimpath", false, "remove the file system paths from the shared libs, e.g. for reproducible builds")
+	flags.BoolVar(&o.Build.Race, "race", false, "enable the race detector in the shared libs")
+	flags.StringVar(&o.Build.Python, "python", "python3", fmt.Sprintf("the python interpreter the extension modules are built for, with -backend=%s or %s", backendCPython, backendCffi))
+	flags.BoolVar(&o.Build.Vet, "vet", true, "vet the generated go files before building them")
+	flags.Var((*fieldsFlag)(&o.Build.VetFlags), "vet-flags", `the space-separated flags of go vet, e.g. "-printf -unusedresult" to only run these analyzers`)
+}
+
+// parse parses the flags of a command, and returns the package patterns.
+// ok is false if the command must exit, with the returned exit code.
+func (o *options) parse(flags *flag.FlagSet, args []string) (patterns []string, code int, ok bool) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, 0, false
+		}
+		return nil, 1, false
+	}
+
+	level := o.LogLevel
+	if level == "" && o.Verbose {
+		level = "INFO"
+	}
+	if level != "" {
+		if err := logging.SetLevel(level); err != nil {
+			printError(err)
+			return nil, 1, false
+		}
+	}
+
+	if err := o.applyConfig(flags); err != nil {
+		printError(err)
+		return nil, 1, false
+	}
+
+	if o.BuildTag != "" && !buildTagRe.MatchString(o.BuildTag) {
+		printError(fmt.Errorf("invalid build tag %q", o.BuildTag))
+		return nil, 1, false
+	}
+
+	switch o.Mode {
+	case modeCShared:
+	case modeCArchive:
+		// the static archives are named like the system ones, unless their name is set
+		soNameSet := false
+		flags.Visit(func(f *flag.Flag) {
+			soNameSet = soNameSet || f.Name == "so-name"
+		})
+		if !soNameSet {
+			o.SoName = defaultArchiveName
+		}
+	default:
+		printError(fmt.Errorf("invalid build mode %q, it must be %s or %s", o.Mode, modeCShared, modeCArchive))
+		return nil, 1, false
+	}
+
+	switch o.Backend {
+	case backendCtypes:
+	case backendCPython, backendCffi:
+		// the extension modules are linked with the shared libs
+		if o.Mode == modeCArchive {
+			printError(fmt.Errorf("the %s backend can't be used with -mode=%s", o.Backend, modeCArchive))
+			return nil, 1, false
+		}
+	default:
+		printError(fmt.Errorf("invalid backend %q, it must be %s, %s or %s", o.Backend, backendCtypes, backendCPython, backendCffi))
+		return nil, 1, false
+	}
+
+	// without patterns, pygo scans the packages of the config, or the current dir
+	patterns = flags.Args()
+	if len(patterns) == 0 {
+		patterns = o.Packages
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	return patterns, 0, true
+}
+
+// printError prints err on stderr, whatever the log level is
+func printError(err error) {
+	log.Printf("[ERROR] %v", err)
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+}
+
+// tagsFlag parses build tags the same way as `go build -tags`
+type tagsFlag []string
+
+func (t *tagsFlag) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagsFlag) Set(value string) error {
+	*t = []string{}
+	// for backward compatibility, go accepts space-separated tags
+	sep := ","
+	if !strings.Contains(value, ",") {
+		sep = " "
+	}
+	for _, tag := range strings.Split(value, sep) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// fieldsFlag parses space-separated values, such as the flags of a command
+type fieldsFlag []string
+
+func (f *fieldsFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *fieldsFlag) Set(value string) error {
+	*f = strings.Fields(value)
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/ast"
+	"github.com/yanndegat/pygo/internal/libfunc"
+	"github.com/yanndegat/pygo/internal/utils"
+)
+
+// configFile is the name of the config file of a module, next to its go.mod
+const configFile = "pygo.json"
+
+// config is the config of the packages of a module, shared by the team instead of repeating flags.
+// The flags set on the command line override it.
+type config struct {
+	// Packages are the package patterns scanned when none is given, relative to the config file
+	Packages []string `json:"packages"`
+	Tags     []string `json:"tags"`
+	Output   string   `json:"output"`
+	SoName   string   `json:"so_name"`
+	PyName   string   `json:"py_name"`
+	BuildTag string   `json:"build_tag"`
+	Mode     string   `json:"mode"`
+	Backend  string   `json:"backend"`
+	// Templates is the dir of the templates overriding the default ones, relative to the config file
+	Templates string `json:"templates"`
+	Build     struct {
+		// Go, LDFlags, GCFlags, TrimPath, Race, Vet, VetFlags and Python are the same as the flags
+		Go       string   `json:"go"`
+		LDFlags  string   `json:"ldflags"`
+		GCFlags  string   `json:"gcflags"`
+		TrimPath *bool    `json:"trimpath"`
+		Race     *bool    `json:"race"`
+		Vet      *bool    `json:"vet"`
+		VetFlags []string `json:"vet_flags"`
+		Python   string   `json:"python"`
+		// Flags are other flags of go build
+		Flags []string `json:"flags"`
+		// Env are the environment variables of go vet and go build, e.g. CGO_CFLAGS
+		Env map[string]string `json:"env"`
+	} `json:"build"`
+	Package struct {
+		// Name, Version and PlatName are the same as the flags of pygo package
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		PlatName string `json:"plat_name"`
+		// Dist is the dir of the python package and its wheel, relative to the config file
+		Dist string `json:"dist"`
+	} `json:"package"`
+	// Types are the converters of named types, by qualified name, e.g. github.com/x/model.Date
+	Types map[string]*typeConfig `json:"types"`
+	// Funcs override the annotations of funcs, by qualified name, e.g. github.com/x/lib.Load
+	Funcs map[string]*funcConfig `json:"funcs"`
+}
+
+type typeConfig struct {
+	// Type is the type exchanged with python, e.g. string
+	Type string `json:"type"`
+	// ToGo is the func converting a value of Type to the named type, e.g. github.com/x/model.ParseDate
+	ToGo string `json:"to_go"`
+	// FromGo is the func converting a value of the named type to Type, e.g. github.com/x/model.FormatDate
+	FromGo string `json:"from_go"`
+}
+
+type funcConfig struct {
+	// Name, Module and Gil are the options of the @pygo.export annotation
+	Name   string `json:"name"`
+	Module string `json:"module"`
+	Gil    string `json:"gil"`
+	// Skip doesn't export the func, like @pygo.skip
+	Skip bool `json:"skip"`
+	// Defaults are the default values of the params, like @pygo.defaults
+	Defaults map[string]string `json:"defaults"`
+}
+
+// options returns the options of the export annotation of the func which are set
+func (c *funcConfig) options() map[string]string {
+	options := map[string]string{}
+	for key, value := range map[string]string{ast.OptionName: c.Name, ast.OptionModule: c.Module, ast.OptionGil: c.Gil} {
+		if value != "" {
+			options[key] = value
+		}
+	}
+	return options
+}
+
+// findConfig returns the path of the config file next to the go.mod of the module of dir, empty if there's none
+func findConfig(dir string) (string, error) {
+	goMod, err := utils.FindFileInParentFolders("go.mod", dir)
+	if err != nil || goMod == "" {
+		return "", err
+	}
+	path := filepath.Join(filepath.Dir(goMod), configFile)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// loadConfig reads and validates a config file. Unknown keys are errors, so that typos don't go unnoticed.
+func loadConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read config %s: %v", path, err)
+	}
+
+	c := &config{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("Couldn't parse config %s: %v", path, err)
+	}
+
+	for name, t := range c.Types {
+		if !strings.Contains(name, ".") {
+			return nil, fmt.Errorf("invalid type %q in config %s, it must be qualified by its import path, e.g. github.com/x/model.Date", name, path)
+		}
+		if t == nil || t.Type == "" {
+			return nil, fmt.Errorf("type %s in config %s has no type", name, path)
+		}
+		if err := libfunc.ValidConverterType(libfunc.Type(t.Type)); err != nil {
+			return nil, fmt.Errorf("invalid type of %s in config %s: %v", name, path, err)
+		}
+	}
+	for name := range c.Funcs {
+		if !strings.Contains(name, ".") {
+			return nil, fmt.Errorf("invalid func %q in config %s, it must be qualified by its import path, e.g. github.com/x/lib.Load", name, path)
+		}
+	}
+	for name := range c.Build.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return nil, fmt.Errorf("invalid environment variable %q in config %s", name, path)
+		}
+	}
+	return c, nil
+}
+
+// applyConfig sets the options from the config file, unless they're set by flags.
+// The config file is the one of the -config flag, or the one found next to the go.mod of the module of the working dir,
+// which applies to all the scanned packages, whatever their module.
+func (o *options) applyConfig(flags *flag.FlagSet) error {
+	path := o.Config
+	if path == "" {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		if path, err = findConfig(pwd); err != nil {
+			return fmt.Errorf("Couldn't find %s: %v", configFile, err)
+		}
+		if path == "" {
+			return nil
+		}
+	}
+
+	c, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	o.configPath = path
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["tags"] && c.Tags != nil {
+		o.Tags = c.Tags
+	}
+	// the flags of go vet may contain spaces, so they aren't joined into the space-separated -vet-flags
+	if !set["vet-flags"] && c.Build.VetFlags != nil {
+		o.Build.VetFlags = c.Build.VetFlags
+	}
+	templates := c.Templates
+	if templates != "" && !filepath.IsAbs(templates) {
+		templates = filepath.Join(filepath.Dir(path), templates)
+	}
+	dist := c.Package.Dist
+	if dist != "" && !filepath.IsAbs(dist) {
+		dist = filepath.Join(filepath.Dir(path), dist)
+	}
+	values := map[string]string{
+		"o":         c.Output,
+		"so-name":   c.SoName,
+		"py-name":   c.PyName,
+		"build-tag": c.BuildTag,
+		"mode":      c.Mode,
+		"backend":   c.Backend,
+		"templates": templates,
+		"go":        c.Build.Go,
+		"ldflags":   c.Build.LDFlags,
+		"gcflags":   c.Build.GCFlags,
+		"python":    c.Build.Python,
+		"name":      c.Package.Name,
+		"version":   c.Package.Version,
+		"dist":      dist,
+		"plat-name": c.Package.PlatName,
+	}
+	for name, value := range map[string]*bool{"trimpath": c.Build.TrimPath, "race": c.Build.Race, "vet": c.Build.Vet} {
+		if value != nil {
+			values[name] = strconv.FormatBool(*value)
+		}
+	}
+	for name, value := range values {
+		// the commands which don't generate files don't have all the flags
+		if set[name] || value == "" || flags.Lookup(name) == nil {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	// the patterns of the config are relative to it, not to the dir pygo is run in
+	for _, pattern := range c.Packages {
+		if strings.HasPrefix(pattern, ".") {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		o.Packages = append(o.Packages, pattern)
+	}
+
+	// the commands which don't build still list the packages with the go binary of the config
+	if flags.Lookup("go") == nil {
+		o.Build.Go = c.Build.Go
+	}
+	o.Build.Flags = c.Build.Flags
+	for name, value := range c.Build.Env {
+		o.Build.Env = append(o.Build.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(o.Build.Env)
+
+	o.Converters = libfunc.Converters{}
+	for name, t := range c.Types {
+		o.Converters[name] = &libfunc.Converter{Type: libfunc.Type(t.Type), ToGo: t.ToGo, FromGo: t.FromGo}
+	}
+	o.Funcs = c.Funcs
+	return nil
+}
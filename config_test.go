@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func TestMain_loadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, configFile)
+
+	tests := []struct {
+		Content string
+		Err     string
+	}{
+		{`{"packages": ["./..."], "types": {"m/model.Date": {"type": "string", "to_go": "m/model.ParseDate"}}}`, ""},
+		{`{"funcs": {"m.Load": {"name": "load", "defaults": {"n": "1"}}}, "build": {"env": {"CGO_CFLAGS": "-O2"}}}`, ""},
+		{`{"pakages": ["./..."]}`, fmt.Sprintf(`Couldn't parse config %s: json: unknown field "pakages"`, path)},
+		{`{"package": {"name": "lib", "dist": "dist"}}`, ""},
+		{`{"types": {"Date": {"type": "string"}}}`, fmt.Sprintf(`invalid type "Date" in config %s, it must be qualified by its import path, e.g. github.com/x/model.Date`, path)},
+		{`{"types": {"m/model.Date": {"to_go": "m/model.ParseDate"}}}`, fmt.Sprintf(`type m/model.Date in config %s has no type`, path)},
+		{`{"types": {"m/model.IDs": {"type": "[]int64"}}}`, ""},
+		{`{"types": {"m/model.Date": {"type": "time"}}}`, fmt.Sprintf(`invalid type of m/model.Date in config %s: time is not supported, it must be one of bool, byte, error, int, int32, int64, string, or a slice of them`, path)},
+		{`{"types": {"m/model.Date": {"type": "*string"}}}`, fmt.Sprintf(`invalid type of m/model.Date in config %s: *string is not supported, it must be one of bool, byte, error, int, int32, int64, string, or a slice of them`, path)},
+		{`{"funcs": {"Load": {}}}`, fmt.Sprintf(`invalid func "Load" in config %s, it must be qualified by its import path, e.g. github.com/x/lib.Load`, path)},
+		{`{"build": {"env": {"A=B": "C"}}}`, fmt.Sprintf(`invalid environment variable "A=B" in config %s`, path)},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(test.Content), 0644); err != nil {
+				t.Fatalf("%v", err)
+			}
+			_, err := loadConfig(path)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+		})
+	}
+}
+
+func TestMain_applyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, configFile)
+	content := `{
+  "packages": ["./...", "example.com/other"],
+  "tags": ["foo"],
+  "output": "build/{{.Pkg}}",
+  "so_name": "_{{.Lib}}",
+  "backend": "cpython",
+  "build": {"ldflags": "-s -w", "trimpath": true, "vet": false, "vet_flags": ["-printf.funcs=Logf,Log Warn"], "python": "python3.11", "flags": ["-a"], "env": {"CGO_LDFLAGS": "-lm", "CC": "clang"}},
+  "types": {"m/model.Date": {"type": "string", "to_go": "m/model.ParseDate", "from_go": "m/model.FormatDate"}}
+}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// the flags set on the command line override the config
+	o := &options{}
+	flags := o.generateFlagSet("build", "")
+	o.buildFlags(flags)
+	patterns, _, ok := o.parse(flags, []string{"-config", path, "-so-name", "lib{{.Lib}}", "-ldflags", "-s -w -X main.version=1"})
+	if !ok {
+		t.Fatalf("match should be %v, was %v", true, ok)
+	}
+
+	if expected := []string{filepath.Join(dir, "..."), "example.com/other"}; !reflect.DeepEqual(patterns, expected) {
+		t.Fatalf("match should be %v, was %v", expected, patterns)
+	}
+	if expected := []string{"foo"}; !reflect.DeepEqual(o.Tags, expected) {
+		t.Fatalf("match should be %v, was %v", expected, o.Tags)
+	}
+	if expected := "build/{{.Pkg}}"; o.Output != expected {
+		t.Fatalf("match should be %v, was %v", expected, o.Output)
+	}
+	if expected := "lib{{.Lib}}"; o.SoName != expected {
+		t.Fatalf("match should be %v, was %v", expected, o.SoName)
+	}
+	if expected := defaultPyName; o.PyName != expected {
+		t.Fatalf("match should be %v, was %v", expected, o.PyName)
+	}
+	b := o.buildOptions()
+	if expected := []string{"-buildmode=c-shared", "-tags=foo", "-ldflags=-s -w -X main.version=1", "-trimpath", "-a"}; !reflect.DeepEqual(b.buildArgs(), expected) {
+		t.Fatalf("match should be %v, was %v", expected, b.buildArgs())
+	}
+	if b.Vet {
+		t.Fatalf("match should be %v, was %v", false, b.Vet)
+	}
+	if expected := []string{"-printf.funcs=Logf,Log Warn"}; !reflect.DeepEqual(b.VetFlags, expected) {
+		t.Fatalf("match should be %v, was %v", expected, b.VetFlags)
+	}
+	if b.Backend != backendCPython || b.Python != "python3.11" {
+		t.Fatalf("match should be %v %v, was %v %v", backendCPython, "python3.11", b.Backend, b.Python)
+	}
+	if expected := []string{"CC=clang", "CGO_LDFLAGS=-lm"}; !reflect.DeepEqual(b.Env, expected) {
+		t.Fatalf("match should be %v, was %v", expected, b.Env)
+	}
+	expected := libfunc.Converters{"m/model.Date": {Type: libfunc.TypeString, ToGo: "m/model.ParseDate", FromGo: "m/model.FormatDate"}}
+	if !reflect.DeepEqual(o.Converters, expected) {
+		t.Fatalf("match should be %v, was %v", expected, o.Converters)
+	}
+}
+
+func TestMain_findConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "pkg", "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module m\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// without a config file next to go.mod, there's no config
+	path, err := findConfig(sub)
+	if err != nil || path != "" {
+		t.Fatalf("match should be %q, was %q (%v)", "", path, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, configFile), []byte("{}"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	path, err = findConfig(sub)
+	if expected := filepath.Join(dir, configFile); err != nil || path != expected {
+		t.Fatalf("match should be %q, was %q (%v)", expected, path, err)
+	}
+}
+
+func TestMain_applyConfig_package(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, configFile)
+	content := `{"package": {"name": "mylib", "version": "1.0.0", "dist": "build/dist", "plat_name": "manylinux2014_x86_64"}}`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	o := &options{}
+	p := &packageOptions{}
+	flags := o.generateFlagSet("package", "")
+	o.buildFlags(flags)
+	p.flags(flags)
+	if _, _, ok := o.parse(flags, []string{"-config", path, "-version", "1.1.0"}); !ok {
+		t.Fatalf("match should be %v, was %v", true, ok)
+	}
+
+	// the dist dir is relative to the config file, and the flags override the config
+	expected := &packageOptions{Name: "mylib", Version: "1.1.0", Dist: filepath.Join(dir, "build", "dist"), PlatName: "manylinux2014_x86_64"}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("match should be %v, was %v", expected, p)
+	}
+
+	// the commands which don't package ignore the keys of the package
+	o = &options{}
+	flags = o.generateFlagSet("build", "")
+	o.buildFlags(flags)
+	if _, _, ok := o.parse(flags, []string{"-config", path}); !ok {
+		t.Fatalf("match should be %v, was %v", true, ok)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/yanndegat/pygo/internal/diff"
+)
+
+// pygoHeaderRe matches the header of the files generated by pygo, which are deleted when they're not generated anymore.
+// It names pygo, so that the files of other generators sharing the output dir are left alone.
+var pygoHeaderRe = regexp.MustCompile(`^(//|#) Code generated by pygo; DO NOT EDIT\.$`)
+
+// genFile is a generated file, rendered in memory before being written or checked
+type genFile struct {
+	Path    string
+	Content []byte
+}
+
+// change is a change of a generated file on disk
+type change struct {
+	Path string
+	// Old is the content of the file on disk, nil if it doesn't exist
+	Old []byte
+	// New is the generated content of the file, nil if it's deleted
+	New []byte
+}
+
+func (c *change) Kind() string {
+	switch {
+	case c.Old == nil:
+		return "created"
+	case c.New == nil:
+		return "deleted"
+	}
+	return "modified"
+}
+
+// diffFiles returns the changes to apply on disk to generate files: the created and modified files,
+// and the files previously generated by pygo in outDirs which aren't generated anymore.
+func diffFiles(outDirs []string, files []*genFile) ([]*change, error) {
+	changes := []*change{}
+	generated := map[string]bool{}
+	for _, f := range files {
+		generated[f.Path] = true
+
+		current, err := ioutil.ReadFile(f.Path)
+		switch {
+		case os.IsNotExist(err):
+			current = nil
+		case err != nil:
+			return nil, fmt.Errorf("Couldn't read file %s: %v", f.Path, err)
+		case bytes.Equal(current, f.Content):
+			continue
+		}
+		changes = append(changes, &change{Path: f.Path, Old: current, New: f.Content})
+	}
+
+	for _, dir := range outDirs {
+		stale, err := staleFiles(dir, generated)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, stale...)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// staleFiles returns the deletion of the files generated by pygo in dir which aren't generated anymore
+func staleFiles(dir string, generated map[string]bool) ([]*change, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []*change{}
+	deleted := map[string]bool{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || generated[path] {
+			continue
+		}
+		switch filepath.Ext(path) {
+		case ".go", ".py", ".pyi", ".typed", ".mod", ".c":
+		default:
+			continue
+		}
+		if ok, err := hasPygoHeader(path); err != nil || !ok {
+			continue
+		}
+
+		c, err := deletion(path)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+		deleted[entry.Name()] = true
+	}
+
+	// the go.sum of a generated go.mod is copied from the module
+	goSum := filepath.Join(dir, "go.sum")
+	if deleted["go.mod"] && !generated[goSum] {
+		if _, err := os.Stat(goSum); err == nil {
+			c, err := deletion(goSum)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func deletion(path string) (*change, error) {
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read file %s: %v", path, err)
+	}
+	return &change{Path: path, Old: current}, nil
+}
+
+// hasPygoHeader returns true if the first line of the file is the header of the files generated by pygo
+func hasPygoHeader(path string) (bool, error) {
+	line, err := firstLine(path)
+	if err != nil {
+		return false, err
+	}
+	return pygoHeaderRe.MatchString(line), nil
+}
+
+// applyChanges writes the created and modified files, creating their dirs, and removes the deleted ones.
+// The unchanged files are left untouched.
+func applyChanges(changes []*change) error {
+	for _, c := range changes {
+		if c.New == nil {
+			if err := os.Remove(c.Path); err != nil {
+				return fmt.Errorf("Couldn't remove file %s: %v", c.Path, err)
+			}
+			log.Printf("[INFO] %s removed, it's not generated anymore", c.Path)
+			continue
+		}
+
+		dir := filepath.Dir(c.Path)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("Couldn't mkdir %s: %v", dir, err)
+		}
+		if err := ioutil.WriteFile(c.Path, c.New, 0644); err != nil {
+			return fmt.Errorf("Couldn't write file %s: %v", c.Path, err)
+		}
+		log.Printf("[DEBUG] %s %s", c.Path, c.Kind())
+	}
+	return nil
+}
+
+// printChanges writes the list of changes, then their unified diff, to w. Paths are printed relative to dir.
+func printChanges(w io.Writer, dir string, changes []*change) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%-8s %s\n", c.Kind(), relPath(dir, c.Path))
+	}
+	for _, c := range changes {
+		name := relPath(dir, c.Path)
+		aName, bName := "a/"+name, "b/"+name
+		if c.Old == nil {
+			aName = "/dev/null"
+		}
+		if c.New == nil {
+			bName = "/dev/null"
+		}
+		fmt.Fprintf(w, "\n%s", diff.Unified(aName, bName, c.Old, c.New))
+	}
+}
+
+// relPath returns path relative to dir, or path itself if it can't be made relative
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain_diffFiles(t *testing.T) {
+	tests := []struct {
+		// OnDisk are the files in the output dir
+		OnDisk map[string]string
+		// Generated are the generated files
+		Generated map[string]string
+		Output    string
+	}{
+		{
+			map[string]string{"lib.py": "a\nb\n"},
+			map[string]string{"lib.py": "a\nb\n"},
+			"",
+		},
+		{
+			map[string]string{"lib.py": "a\nc\n"},
+			map[string]string{"lib.py": "a\nb\n"},
+			"modified out/lib.py\n\n--- a/out/lib.py\n+++ b/out/lib.py\n@@ -1,2 +1,2 @@\n a\n-c\n+b\n",
+		},
+		{
+			map[string]string{},
+			map[string]string{"lib.py": "a\n"},
+			"created  out/lib.py\n\n--- /dev/null\n+++ b/out/lib.py\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			map[string]string{
+				"lib.py":   "# Code generated by pygo; DO NOT EDIT.\n",
+				"old.py":   "# Code generated by pygo; DO NOT EDIT.\n",
+				"old.pyi":  "# Code generated by pygo; DO NOT EDIT.\n",
+				"other.go": "// Code generated by stringer; DO NOT EDIT.\n",
+				"gen.py":   "# Code generated by go generate; DO NOT EDIT.\n",
+				"mine.py":  "a\n",
+				"go.mod":   "// Code generated by pygo; DO NOT EDIT.\n",
+				"go.sum":   "s\n",
+			},
+			map[string]string{"lib.py": "# Code generated by pygo; DO NOT EDIT.\n"},
+			"deleted  out/go.mod\ndeleted  out/go.sum\ndeleted  out/old.py\ndeleted  out/old.pyi\n" +
+				"\n--- a/out/go.mod\n+++ /dev/null\n@@ -1 +0,0 @@\n-// Code generated by pygo; DO NOT EDIT.\n" +
+				"\n--- a/out/go.sum\n+++ /dev/null\n@@ -1 +0,0 @@\n-s\n" +
+				"\n--- a/out/old.py\n+++ /dev/null\n@@ -1 +0,0 @@\n-# Code generated by pygo; DO NOT EDIT.\n" +
+				"\n--- a/out/old.pyi\n+++ /dev/null\n@@ -1 +0,0 @@\n-# Code generated by pygo; DO NOT EDIT.\n",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pygo")
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			outDir := filepath.Join(dir, "out")
+			if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+				t.Fatalf("%v", err)
+			}
+			for name, content := range test.OnDisk {
+				if err := ioutil.WriteFile(filepath.Join(outDir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}
+			files := []*genFile{}
+			for name, content := range test.Generated {
+				files = append(files, &genFile{Path: filepath.Join(outDir, name), Content: []byte(content)})
+			}
+
+			changes, err := diffFiles([]string{outDir}, files)
+			if err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+			var buf bytes.Buffer
+			printChanges(&buf, dir, changes)
+			if buf.String() != test.Output {
+				t.Fatalf("match should be %q, was %q", test.Output, buf.String())
+			}
+
+			// the files on disk are only changed by applying the changes
+			for name, content := range test.OnDisk {
+				data, _ := ioutil.ReadFile(filepath.Join(outDir, name))
+				if string(data) != content {
+					t.Fatalf("match should be %q, was %q", content, data)
+				}
+			}
+
+			if err := applyChanges(changes); err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+			changes, err = diffFiles([]string{outDir}, files)
+			if err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+			if len(changes) != 0 {
+				t.Fatalf("match should be %v, was %v", 0, len(changes))
+			}
+		})
+	}
+}
+
+func TestMain_plan_nothingExported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	outDir := filepath.Join(dir, "pygo")
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for name, content := range map[string]string{
+		"lib.go":   "// Code generated by pygo; DO NOT EDIT.\n",
+		"lib.py":   "# Code generated by pygo; DO NOT EDIT.\n",
+		"py.typed": "# Code generated by pygo; DO NOT EDIT.\n",
+		"mine.py":  "a\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(outDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	// the files generated before the last export was removed are stale
+	p := &pyPkg{Dir: dir, ImportPath: "m/lib", OutDir: outDir}
+	changes, err := plan([]*pyPkg{p}, &options{Jobs: 1})
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	deleted := []string{}
+	for _, c := range changes {
+		if c.New != nil {
+			t.Fatalf("match should be %v, was %v", "deleted", c.Kind())
+		}
+		deleted = append(deleted, filepath.Base(c.Path))
+	}
+	if expected := []string{"lib.go", "lib.py", "py.typed"}; fmt.Sprint(deleted) != fmt.Sprint(expected) {
+		t.Fatalf("match should be %v, was %v", expected, deleted)
+	}
+	if exported := exportedPkgs([]*pyPkg{p}); len(exported) != 0 {
+		t.Fatalf("match should be %v, was %v", 0, len(exported))
+	}
+}
@@ -0,0 +1,765 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yanndegat/pygo/internal/ast"
+	"github.com/yanndegat/pygo/internal/diags"
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+// loadPkgs lists the packages matching patterns, and loads the python libs to generate from them.
+// Problems are collected across all packages, so that they're all reported at once.
+func loadPkgs(dir string, patterns []string, o *options) ([]*pyPkg, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+
+	pkgs, err := ast.ListPackages(dir, patterns, o.Tags, o.Build.Go, o.Build.Env)
+	if err != nil {
+		return nil, diagnostics.Append(fmt.Errorf("Couldn't list packages %v: %v", patterns, err))
+	}
+	return loadAstPkgs(pkgs, o)
+}
+
+// loadAstPkgs loads the python libs to generate from listed packages
+func loadAstPkgs(pkgs []*ast.Package, o *options) ([]*pyPkg, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+
+	pyPkgs := []*pyPkg{}
+	loaded := map[string]bool{}
+	overridden := map[string]bool{}
+	for _, pkg := range pkgs {
+		loaded[pkg.ImportPath] = true
+		p, pkgDiags := loadPkg(pkg, o, overridden)
+		diagnostics = diagnostics.Append(pkgDiags)
+		if pkgDiags.HasErrors() {
+			continue
+		}
+		// the packages with nothing to export are kept, so that the files they had generated are removed
+		if len(p.Libs) == 0 {
+			log.Printf("[DEBUG] package %s has nothing to export", pkg)
+		}
+		pyPkgs = append(pyPkgs, p)
+	}
+
+	// the generated packages can't share a dir
+	outDirs := map[string]*pyPkg{}
+	for _, p := range exportedPkgs(pyPkgs) {
+		if prev, ok := outDirs[p.OutDir]; ok {
+			diagnostics = diagnostics.Append(fmt.Errorf("packages %s and %s would both be generated in %s", prev.ImportPath, p.ImportPath, p.OutDir))
+			continue
+		}
+		outDirs[p.OutDir] = p
+	}
+
+	// the extension modules link their shared lib by file name, so the loader would share
+	// the first shared lib loaded with the extension modules of the others of the same name
+	if hasExt(o.Backend) {
+		sharedLibs := map[string]*pyPkg{}
+		for _, p := range exportedPkgs(pyPkgs) {
+			for _, pyLib := range p.Libs {
+				if prev, ok := sharedLibs[pyLib.SharedLib]; ok {
+					diagnostics = diagnostics.Append(fmt.Errorf("packages %s and %s would both build %s, which can't be loaded twice with -backend=%s, generate one of them with another -so-name", prev.ImportPath, p.ImportPath, pyLib.SharedLib, o.Backend))
+					continue
+				}
+				sharedLibs[pyLib.SharedLib] = p
+			}
+		}
+	}
+
+	// the funcs of the config file which don't match any func are most likely typos
+	names := make([]string, 0, len(o.Funcs))
+	for name := range o.Funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if loaded[name[:strings.LastIndex(name, ".")]] && !overridden[name] {
+			diagnostics = diagnostics.Append(diags.Warningf(token.Position{}, "func %s of %s doesn't match any func annotated with @pygo.export or exported by @pygo.exportall", name, o.configPath))
+		}
+	}
+	return pyPkgs, diagnostics
+}
+
+// exportedPkgs returns the packages which have something to export, and so libs to build
+func exportedPkgs(pyPkgs []*pyPkg) []*pyPkg {
+	exported := []*pyPkg{}
+	for _, p := range pyPkgs {
+		if len(p.Libs) > 0 {
+			exported = append(exported, p)
+		}
+	}
+	return exported
+}
+
+// generate writes the go and python files of the libs of each package in its output dir
+func generate(pyPkgs []*pyPkg, o *options) error {
+	changes, err := plan(pyPkgs, o)
+	if err != nil {
+		return err
+	}
+	return applyChanges(changes)
+}
+
+// plan returns the changes of the generated files of each package on disk, without applying them
+func plan(pyPkgs []*pyPkg, o *options) ([]*change, error) {
+	files, err := render(pyPkgs, o)
+	if err != nil {
+		return nil, err
+	}
+
+	outDirs := make([]string, len(pyPkgs))
+	for i, p := range pyPkgs {
+		outDirs[i] = p.OutDir
+	}
+	return diffFiles(outDirs, files)
+}
+
+// render renders the go and python files of the libs of each package, without writing them.
+// The packages are rendered in parallel.
+func render(pyPkgs []*pyPkg, o *options) ([]*genFile, error) {
+	timestamp := ""
+	if o.Timestamp {
+		timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	tpls, err := loadTemplates(o.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgFiles := make([][]*genFile, len(pyPkgs))
+	jobs := make([]func(l *jobLog) error, len(pyPkgs))
+	for i, p := range pyPkgs {
+		i, p := i, p
+		jobs[i] = func(l *jobLog) error {
+			files, err := renderPkg(p, tpls, o.BuildTag, o.Backend, timestamp)
+			pkgFiles[i] = files
+			return err
+		}
+	}
+	if err := runJobs(o.jobs(), jobs); err != nil {
+		return nil, err
+	}
+
+	files := []*genFile{}
+	for _, f := range pkgFiles {
+		files = append(files, f...)
+	}
+	return files, nil
+}
+
+// renderPkg renders the go and python files of the libs of a package,
+// and the C files of their extension modules with the cpython backend
+func renderPkg(p *pyPkg, tpls *template.Template, buildTag, backend, timestamp string) ([]*genFile, error) {
+	files := []*genFile{}
+	// the generated package can't be built outside of a module
+	if len(p.Libs) > 0 && outsideModule(p.OutDir, p.Mod) {
+		modFiles, err := renderGoMod(p.OutDir, p.ImportPath, p.Mod)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't generate go.mod in %s: %v", p.OutDir, err)
+		}
+		files = append(files, modFiles...)
+	}
+
+	for _, pyLib := range p.Libs {
+		lib := pyLib.Name
+		// generate lib.go
+		f, err := renderPygo(tpls, p.OutDir, p.Mod, pyLib, buildTag, timestamp)
+		if err == nil {
+			err = checkHeader(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't generate %s.go in %s: %v", lib, p.OutDir, err)
+		}
+		files = append(files, f)
+
+		// generate the source of the extension module, which the python modules import
+		if hasExt(backend) {
+			f, err := renderExt(tpls, p.OutDir, p.Mod, pyLib, backend, timestamp)
+			if err == nil {
+				err = checkHeader(f)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't generate %s in %s: %v", extSource(pyLib.SharedLib, backend), p.OutDir, err)
+			}
+			files = append(files, f)
+		}
+
+		// generate the main python module, and one py file per additional module, with their stubs
+		for _, module := range pyLib.Modules() {
+			f, err := renderPy(tpls, p.OutDir, lib, module, backend, p.Mod, pyLib.Module(module), timestamp)
+			if err == nil {
+				err = checkHeader(f)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't generate %s.py in %s: %v", module, p.OutDir, err)
+			}
+			files = append(files, f)
+
+			f, err = renderStub(tpls, p.OutDir, lib, module, p.Mod, pyLib.Module(module), timestamp)
+			if err == nil {
+				err = checkHeader(f)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't generate %s.pyi in %s: %v", module, p.OutDir, err)
+			}
+			files = append(files, f)
+		}
+	}
+
+	// the stubs mark the output dir as typed
+	if len(p.Libs) > 0 {
+		files = append(files, &genFile{Path: filepath.Join(p.OutDir, pyTypedFile), Content: []byte(pyTypedHeader + "\n")})
+	}
+	return files, nil
+}
+
+// buildOptions are the options of the go vet and go build of the generated files
+type buildOptions struct {
+	Tags []string
+	// Mode is the -buildmode of go build, c-shared or c-archive
+	Mode string
+	// Go is the go binary
+	Go string
+	// LDFlags and GCFlags are the -ldflags and -gcflags of go build
+	LDFlags string
+	GCFlags string
+	// TrimPath and Race set -trimpath and -race on go build
+	TrimPath bool
+	Race     bool
+	// Vet vets the generated files before building them, with the VetFlags of go vet
+	Vet      bool
+	VetFlags []string
+	// Flags are other flags of go build
+	Flags []string
+	// Env are NAME=value environment variables, added to the ones of pygo
+	Env []string
+	// Backend is the backend of the python modules, and Python the python interpreter
+	// the C extension modules of the cpython backend are built for
+	Backend string
+	Python  string
+
+	// python is the config of Python, resolved by build with the cpython backend
+	python *pythonConfig
+}
+
+// buildOptions returns the options of the builds of the generated files
+func (o *options) buildOptions() *buildOptions {
+	b := o.Build
+	b.Tags = o.Tags
+	b.Mode = o.Mode
+	if b.Mode == "" {
+		b.Mode = modeCShared
+	}
+	b.Backend = o.Backend
+	if b.Backend == "" {
+		b.Backend = backendCtypes
+	}
+	if o.BuildTag != "" {
+		b.Tags = append(append([]string{}, o.Tags...), o.BuildTag)
+	}
+	if b.Go == "" {
+		b.Go = "go"
+	}
+	if b.Python == "" {
+		b.Python = "python3"
+	}
+	return &b
+}
+
+// buildArgs returns the flags of go build, except its output
+func (b *buildOptions) buildArgs() []string {
+	args := []string{fmt.Sprintf("-buildmode=%s", b.Mode), fmt.Sprintf("-tags=%s", strings.Join(b.Tags, ","))}
+	if b.LDFlags != "" {
+		args = append(args, fmt.Sprintf("-ldflags=%s", b.LDFlags))
+	}
+	if b.GCFlags != "" {
+		args = append(args, fmt.Sprintf("-gcflags=%s", b.GCFlags))
+	}
+	if b.TrimPath {
+		args = append(args, "-trimpath")
+	}
+	if b.Race {
+		args = append(args, "-race")
+	}
+	return append(args, b.Flags...)
+}
+
+// command returns a go command run in dir, writing its output to out
+func (b *buildOptions) command(out io.Writer, dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command(b.Go, args...)
+	cmd.Dir = dir
+	cmd.Stderr = out
+	cmd.Stdout = out
+	if len(b.Env) > 0 {
+		cmd.Env = append(os.Environ(), b.Env...)
+	}
+	return cmd
+}
+
+// build builds the shared libs of the generated packages, after vetting them if asked to by b.
+// The shared libs whose build stamp didn't change are skipped, unless force is set.
+// The packages are vetted and built in parallel, on at most jobs workers.
+func build(pyPkgs []*pyPkg, b *buildOptions, force bool, jobs int) error {
+	// the extension modules are built after the shared libs, so an unsupported platform fails first
+	if hasExt(b.Backend) {
+		if _, err := extLinkArgs(runtime.GOOS, ""); err != nil {
+			return fmt.Errorf("Couldn't build with -backend=%s: %v", b.Backend, err)
+		}
+	}
+	if hasExt(b.Backend) && b.python == nil {
+		python, err := b.pythonConfig()
+		if err != nil {
+			return err
+		}
+		b.python = python
+	}
+
+	pkgJobs := []func(l *jobLog) error{}
+	for _, p := range pyPkgs {
+		p := p
+		pkgJobs = append(pkgJobs, func(l *jobLog) error {
+			return buildPkg(l, p, b, force)
+		})
+	}
+	return runJobs(jobs, pkgJobs)
+}
+
+// buildPkg builds the shared libs of a generated package, logging to l
+func buildPkg(l *jobLog, p *pyPkg, b *buildOptions, force bool) error {
+	type target struct {
+		Lib   *libfunc.Lib
+		Stamp string
+	}
+	targets := []*target{}
+	for _, pyLib := range p.Libs {
+		stamp, err := buildStamp(p, pyLib, b)
+		if err != nil {
+			return fmt.Errorf("Couldn't compute the build stamp of %s in %s: %v", pyLib.SharedLib, p.OutDir, err)
+		}
+		if !force && upToDate(p.OutDir, pyLib, b, stamp) {
+			l.Printf("[INFO] shared lib %s is up to date", filepath.Join(p.OutDir, pyLib.SharedLib))
+			continue
+		}
+		targets = append(targets, &target{Lib: pyLib, Stamp: stamp})
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	if b.Vet {
+		// the packages outside of the module are vetted in their own module
+		dir, pygoDir := p.Dir, p.OutDir
+		if outsideModule(p.OutDir, p.Mod) {
+			dir, pygoDir = p.OutDir, "."
+		}
+		l.Printf("[INFO] vet %s", p.OutDir)
+		if err := vetLibs(l.Writer(), dir, pygoDir, b); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range targets {
+		l.Printf("[INFO] build shared lib %s", filepath.Join(p.OutDir, t.Lib.SharedLib))
+		if err := generateLibso(l.Writer(), p.OutDir, t.Lib, b); err != nil {
+			return err
+		}
+		if hasExt(b.Backend) {
+			l.Printf("[INFO] build extension module %s", filepath.Join(p.OutDir, b.extFile(t.Lib)))
+			if err := buildExt(l.Writer(), p.OutDir, t.Lib, b); err != nil {
+				return err
+			}
+		}
+		if err := writeStamp(p.OutDir, t.Lib, t.Stamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pyPkg is a scanned go package, and the python libs generated from it
+type pyPkg struct {
+	Dir        string
+	ImportPath string
+	Mod        *ast.Mod
+	Libs       []*libfunc.Lib
+	// OutDir is the dir in which the files of the libs are generated
+	OutDir string
+	// BuildInputs are the compiled files of the package and of its dependencies
+	BuildInputs []string
+	// Rejected are the funcs and structs of the package which aren't exported, with the reason why
+	Rejected []*rejectedDecl
+}
+
+// rejectedDecl is an annotated func or struct which isn't exported. Either Func or Struct is set,
+// with the types of its params or fields if they could be converted.
+type rejectedDecl struct {
+	Func   *libfunc.Func
+	Struct *libfunc.Struct
+	// Status is why it's rejected, one of the status* constants, and Reason the details
+	Status string
+	Reason string
+	// Implicit is true if it's exported by @pygo.exportall rather than by its own annotation
+	Implicit bool
+}
+
+const (
+	// statusExported is the status of the funcs and structs which are exported
+	statusExported = "exported"
+	// statusSkipped is the status of the funcs skipped by the config file
+	statusSkipped = "skipped"
+	// statusInvalid is the status of the funcs and structs whose declaration or settings are invalid
+	statusInvalid = "invalid"
+	// statusUnsupported is the status of the funcs and structs with types which can't be exchanged with python,
+	// or which the backend can't call
+	statusUnsupported = "unsupported"
+)
+
+// loadPkg loads the python libs to generate from a package.
+// The funcs overridden by the config file are added to overridden.
+func loadPkg(pkg *ast.Package, o *options, overridden map[string]bool) (*pyPkg, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+	dir := pkg.Dir
+
+	mod, err := ast.ParseMod(dir)
+	if err != nil {
+		return nil, diagnostics.Append(fmt.Errorf("Couldn't parse mod info of %s: %v", dir, err))
+	}
+	log.Printf("[INFO] mod is %v", mod)
+
+	// the funcs which could be parsed are still converted,
+	// so that all problems are reported
+	astLibs, astDiags := ast.ParsePackage(pkg, o.Tags)
+	diagnostics = diagnostics.Append(astDiags)
+	if astLibs == nil {
+		return nil, diagnostics
+	}
+
+	outDir, err := o.outDir(newLayoutData(pkg.Name, pkg.ImportPath, dir, mod))
+	if err != nil {
+		return nil, diagnostics.Append(err)
+	}
+
+	p := &pyPkg{
+		Dir:         dir,
+		ImportPath:  pkg.ImportPath,
+		Mod:         mod,
+		Libs:        []*libfunc.Lib{},
+		OutDir:      outDir,
+		BuildInputs: pkg.BuildInputs(),
+	}
+	// the libs are sorted, so that the generated files don't depend on the map order
+	libs := make([]string, 0, len(astLibs))
+	for lib := range astLibs {
+		libs = append(libs, lib)
+	}
+	sort.Strings(libs)
+
+	for _, lib := range libs {
+		astLib := astLibs[lib]
+		fs := []*libfunc.Func{}
+		for _, astF := range astLib.Funcs {
+			// the funcs which can't be converted are only known by their declaration
+			reject := func(f *libfunc.Func, status string, err error) {
+				if f == nil {
+					f = libfunc.DeclaredFunc(lib, astF, o.Converters)
+				}
+				p.Rejected = append(p.Rejected, &rejectedDecl{Func: f, Status: status, Reason: err.Error(), Implicit: astF.Implicit})
+			}
+
+			if fc, ok := o.Funcs[pkg.ImportPath+"."+astF.Name]; ok {
+				overridden[pkg.ImportPath+"."+astF.Name] = true
+				if fc.Skip {
+					log.Printf("[DEBUG] func %s from lib %s is skipped by %s", astF.Name, lib, o.configPath)
+					reject(nil, statusSkipped, fmt.Errorf("skipped by %s", o.configPath))
+					continue
+				}
+				if err := astF.Override(fc.options(), fc.Defaults); err != nil {
+					diagnostics = diagnostics.Append(diags.Errorf(astF.Pos, "invalid settings of func %s in %s: %v", astF.Name, o.configPath, err))
+					reject(nil, statusInvalid, fmt.Errorf("invalid settings in %s: %v", o.configPath, err))
+					continue
+				}
+				// the funcs of the config file are exported explicitly
+				astF.Implicit = false
+			}
+
+			f, err := libfunc.ConvertFromAstF(lib, astF, o.Converters)
+			if err != nil && astF.Implicit {
+				log.Printf("[DEBUG] func %s from lib %s is not exported: %v", astF.Name, lib, err)
+				reject(nil, statusUnsupported, err)
+				continue
+			}
+			if err != nil {
+				diagnostics = diagnostics.Append(diags.Errorf(astF.Pos, "func %s can't be exported: %v", astF.Name, err))
+				reject(nil, statusInvalid, err)
+				continue
+			}
+			log.Printf("[DEBUG] adding func to lib %s: %v", lib, f)
+
+			if err := f.SupportError(); err != nil {
+				// funcs exported by @pygo.exportall are only exported if they're supported
+				// unresolved types are already reported as errors
+				if astF.Implicit || astDiags.HasErrors() {
+					log.Printf("[DEBUG] func %v from lib %s is not supported, it's not exported: %v", f, lib, err)
+				} else {
+					diagnostics = diagnostics.Append(diags.Warningf(f.Pos, "func %s is not supported, it's not exported: %v", f.Name, err))
+				}
+				reject(f, statusUnsupported, err)
+				continue
+			}
+			if hasExt(o.Backend) {
+				if err := f.CSupportError(); err != nil {
+					diagnostics = diagnostics.Append(diags.Warningf(f.Pos, "func %s is not supported by the %s backend, it's not exported: %v", f.Name, o.Backend, err))
+					reject(f, statusUnsupported, fmt.Errorf("not supported by the %s backend: %v", o.Backend, err))
+					continue
+				}
+			}
+			fs = append(fs, f)
+
+		}
+
+		ss := []*libfunc.Struct{}
+		for _, astS := range astLib.Structs {
+			s, err := libfunc.ConvertFromAstS(lib, astS, o.Converters)
+			if err != nil {
+				diagnostics = diagnostics.Append(diags.Errorf(astS.Pos, "struct %s can't be exported: %v", astS.Name, err))
+				s = &libfunc.Struct{Lib: lib, Name: astS.Name, PyName: astS.Name, Module: lib, Doc: astS.Doc, Pos: astS.Pos}
+				p.Rejected = append(p.Rejected, &rejectedDecl{Struct: s, Status: statusInvalid, Reason: err.Error()})
+				continue
+			}
+			log.Printf("[DEBUG] adding struct to lib %s: %v", lib, s)
+
+			if err := s.SupportError(); err != nil {
+				if astDiags.HasErrors() {
+					log.Printf("[DEBUG] struct %v from lib %s is not supported, it's not exported: %v", s, lib, err)
+				} else {
+					diagnostics = diagnostics.Append(diags.Warningf(s.Pos, "struct %s is not supported, it's not exported: %v", s.Name, err))
+				}
+				p.Rejected = append(p.Rejected, &rejectedDecl{Struct: s, Status: statusUnsupported, Reason: err.Error()})
+				continue
+			}
+			ss = append(ss, s)
+		}
+
+		if len(fs) == 0 && len(ss) == 0 {
+			continue
+		}
+
+		pyLib := libfunc.NewLib(lib, fs, ss, astLib.Doc)
+		sharedLib, pyModule, err := o.names(newLayoutData(lib, pkg.ImportPath, dir, mod))
+		if err != nil {
+			diagnostics = diagnostics.Append(err)
+			continue
+		}
+		pyLib.SetNames(sharedLib, pyModule)
+		pyLib.SetStatic(o.Mode == modeCArchive)
+		pyLib.SetRaisesErrors(hasExt(o.Backend))
+
+		diagnostics = diagnostics.Append(checkNameClashes(pyLib))
+		if _, err := pyLib.GoImports(); err != nil {
+			diagnostics = diagnostics.Append(err)
+		}
+		p.Libs = append(p.Libs, pyLib)
+	}
+	return p, diagnostics
+}
+
+// checkNameClashes checks that the python names of a lib are unique in their module
+func checkNameClashes(pyLib *libfunc.Lib) diags.Diagnostics {
+	var diagnostics diags.Diagnostics
+
+	type declared struct {
+		Name string
+		Pos  token.Position
+	}
+	names := map[string]declared{}
+	check := func(module, pyName, name string, pos token.Position) {
+		key := fmt.Sprintf("%s.%s", module, pyName)
+		if prev, ok := names[key]; ok {
+			diagnostics = diagnostics.Append(diags.Errorf(pos, "%s is exported as %s, which is already the python name of %s at %s:%d:%d",
+				name, key, prev.Name, filepath.Base(prev.Pos.Filename), prev.Pos.Line, prev.Pos.Column))
+			return
+		}
+		names[key] = declared{Name: name, Pos: pos}
+	}
+
+	for _, s := range pyLib.Structs {
+		check(s.Module, s.PyName, s.Name, s.Pos)
+	}
+	for _, f := range pyLib.Funcs {
+		check(f.Module, f.PyName, f.Name, f.Pos)
+	}
+	return diagnostics
+}
+
+// printDiags prints the diagnostics on stderr, whatever the log level is
+func printDiags(dir string, diagnostics diags.Diagnostics) {
+	diagnostics.Sort()
+	for _, d := range diagnostics {
+		log.Printf("[DEBUG] diagnostic: %v", d)
+		fmt.Fprintln(os.Stderr, d.Format(dir))
+	}
+}
+
+func vetLibs(out io.Writer, dir, pygoDir string, b *buildOptions) error {
+	args := append([]string{"vet", fmt.Sprintf("-tags=%s", strings.Join(b.Tags, ","))}, b.VetFlags...)
+	cmd := b.command(out, dir, append(args, pygoDir)...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go vet failed on %s: %v", pygoDir, err)
+	}
+	return nil
+}
+
+// generateLibso builds the shared lib of a lib, or its static archive with its linker flags with -mode=c-archive.
+// go build writes the C header of the lib next to it.
+func generateLibso(out io.Writer, dir string, pyLib *libfunc.Lib, b *buildOptions) error {
+	goFile := fmt.Sprintf("%s.go", pyLib.Name)
+	args := append([]string{"build"}, b.buildArgs()...)
+	args = append(args, "-o", pyLib.SharedLib, goFile)
+	cmd := b.command(out, dir, args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Couldn't generate %s in %s: %v", pyLib.SharedLib, dir, err)
+	}
+	if b.Mode != modeCArchive {
+		return nil
+	}
+
+	// a static archive doesn't record the system libs it needs, such as -lpthread,
+	// which are the cgo linker flags of the generated package and of its dependencies
+	var stdout bytes.Buffer
+	cmd = b.command(out, dir, "list", "-deps", fmt.Sprintf("-tags=%s", strings.Join(b.Tags, ",")), "-f", `{{ join .CgoLDFLAGS " " }}`, goFile)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Couldn't list the linker flags of %s in %s: %v", pyLib.SharedLib, dir, err)
+	}
+	// the flags are deduplicated by package, as some go by pairs, e.g. -framework CoreFoundation
+	ldflags := []string{}
+	seen := map[string]bool{}
+	for _, flags := range strings.Split(stdout.String(), "\n") {
+		if flags = strings.TrimSpace(flags); flags != "" && !seen[flags] {
+			seen[flags] = true
+			ldflags = append(ldflags, flags)
+		}
+	}
+
+	path := filepath.Join(dir, strings.TrimSuffix(pyLib.SharedLib, filepath.Ext(pyLib.SharedLib))+ldflagsExt)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(ldflags, " ")+"\n"), 0644); err != nil {
+		return fmt.Errorf("Couldn't write file %s: %v", path, err)
+	}
+	return nil
+}
+
+func renderPygo(tpls *template.Template, dir string, mod *ast.Mod, pyLib *libfunc.Lib, buildTag, timestamp string) (*genFile, error) {
+	lib := pyLib.Name
+	imports, err := pyLib.GoImports()
+	if err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(dir, fmt.Sprintf("%s.go", lib))
+	var buf bytes.Buffer
+	err = tpls.ExecuteTemplate(&buf, goTemplate, &goFileData{
+		Timestamp: timestamp,
+		Lib:       lib,
+		Mod:       mod,
+		Dir:       dir,
+		Funcs:     pyLib.Funcs,
+		Imports:   imports,
+		BuildTag:  buildTag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fmtSrc, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Printf("[DEBUG] Source before formatting is:\n%v", string(buf.Bytes()))
+		return nil, fmt.Errorf("Couldn't format source for %s: %v", filePath, err)
+	}
+	return &genFile{Path: filePath, Content: fmtSrc}, nil
+}
+
+// renderPy renders a python module of a lib, which imports the extension module of the lib
+// with the cpython and cffi backends
+func renderPy(tpls *template.Template, dir, lib, module, backend string, mod *ast.Mod, pyLib *libfunc.Lib, timestamp string) (*genFile, error) {
+	name, ext := pyTemplate, ""
+	switch backend {
+	case backendCPython:
+		name, ext = pyExtTemplate, extModule(pyLib.SharedLib, backend)
+	case backendCffi:
+		name, ext = pyCffiTemplate, extModule(pyLib.SharedLib, backend)
+	}
+	var buf bytes.Buffer
+	err := tpls.ExecuteTemplate(&buf, name, &pyFileData{
+		Timestamp: timestamp,
+		Lib:       lib,
+		Mod:       mod,
+		Dir:       dir,
+		Funcs:     pyLib.Funcs,
+		Structs:   pyLib.Structs,
+		Doc:       pyLib.PyDoc(),
+		All:       pyLib.PyAll(),
+		Ext:       ext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &genFile{Path: filepath.Join(dir, fmt.Sprintf("%s.py", module)), Content: buf.Bytes()}, nil
+}
+
+// renderStub renders the stub of a python module of a lib, with the type hints of its funcs and classes
+func renderStub(tpls *template.Template, dir, lib, module string, mod *ast.Mod, pyLib *libfunc.Lib, timestamp string) (*genFile, error) {
+	var buf bytes.Buffer
+	err := tpls.ExecuteTemplate(&buf, pyStubTemplate, &pyFileData{
+		Timestamp: timestamp,
+		Lib:       lib,
+		Mod:       mod,
+		Dir:       dir,
+		Funcs:     pyLib.Funcs,
+		Structs:   pyLib.Structs,
+		Doc:       pyLib.PyDoc(),
+		All:       pyLib.PyAll(),
+		Typing:    pyLib.PyTyping(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &genFile{Path: filepath.Join(dir, fmt.Sprintf("%s.pyi", module)), Content: buf.Bytes()}, nil
+}
+
+// renderExt renders the source of the extension module of a lib with a backend:
+// the C extension module of the cpython backend, or the build script of the cffi one
+func renderExt(tpls *template.Template, dir string, mod *ast.Mod, pyLib *libfunc.Lib, backend, timestamp string) (*genFile, error) {
+	name := extTemplate
+	if backend == backendCffi {
+		name = cffiBuildTemplate
+	}
+	var buf bytes.Buffer
+	err := tpls.ExecuteTemplate(&buf, name, &extFileData{
+		Timestamp: timestamp,
+		Lib:       pyLib.Name,
+		Mod:       mod,
+		Dir:       dir,
+		Funcs:     pyLib.Funcs,
+		Module:    extModule(pyLib.SharedLib, backend),
+		Header:    libHeader(pyLib.SharedLib),
+		SharedLib: pyLib.SharedLib,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &genFile{Path: filepath.Join(dir, extSource(pyLib.SharedLib, backend)), Content: buf.Bytes()}, nil
+}
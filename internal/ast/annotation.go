@@ -0,0 +1,331 @@
+package ast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/diags"
+)
+
+const (
+	annotationNamespace = "pygo"
+
+	AnnotationExport    = "export"
+	AnnotationExportAll = "exportall"
+	AnnotationSkip      = "skip"
+	AnnotationDefaults  = "defaults"
+
+	OptionName   = "name"
+	OptionModule = "module"
+	OptionGil    = "gil"
+
+	GilRelease = "release"
+	GilHold    = "hold"
+)
+
+var (
+	pyIdentifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// pyKeywords can't be python identifiers
+	pyKeywords = map[string]bool{
+		"False": true, "None": true, "True": true, "and": true, "as": true,
+		"assert": true, "async": true, "await": true, "break": true, "class": true,
+		"continue": true, "def": true, "del": true, "elif": true, "else": true,
+		"except": true, "finally": true, "for": true, "from": true, "global": true,
+		"if": true, "import": true, "in": true, "is": true, "lambda": true,
+		"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+		"return": true, "try": true, "while": true, "with": true, "yield": true,
+	}
+
+	// annotationOptions lists the options each annotation accepts
+	annotationOptions = map[string][]string{
+		AnnotationExport: {OptionName, OptionModule, OptionGil},
+	}
+
+	// freeOptionsAnnotations accept any option name, e.g. param names
+	freeOptionsAnnotations = map[string]bool{
+		AnnotationDefaults: true,
+	}
+)
+
+// PyIdentifier returns name, suffixed with _ if it's a python keyword
+func PyIdentifier(name string) string {
+	if pyKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// Annotation is a `@pygo.<name>(<key>=<value>, ...)` annotation
+// found in a comment.
+type Annotation struct {
+	Name    string
+	Options map[string]string
+	// Offset is the offset of the annotation in the comment text
+	Offset int
+}
+
+func (a *Annotation) String() string {
+	if len(a.Options) == 0 {
+		return fmt.Sprintf("@%s.%s", annotationNamespace, a.Name)
+	}
+
+	keys := make([]string, 0, len(a.Options))
+	for key := range a.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	opts := make([]string, len(keys))
+	for i, key := range keys {
+		opts[i] = fmt.Sprintf("%s=%q", key, a.Options[key])
+	}
+	return fmt.Sprintf("@%s.%s(%s)", annotationNamespace, a.Name, strings.Join(opts, ", "))
+}
+
+// AnnotationError is a parse error located at Offset in the comment text
+type AnnotationError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *AnnotationError) Error() string {
+	return e.Msg
+}
+
+// commentAnnotations parses the annotations of a comment, and validates them
+// against the list of annotations allowed in this context.
+// Problems are diagnostics located at the exact position of the problem in the file.
+func commentAnnotations(fset *token.FileSet, comm *ast.Comment, allowed ...string) ([]*Annotation, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+	annotations, warnings, err := parseAnnotations(comm.Text)
+	for _, w := range warnings {
+		diagnostics = diagnostics.Append(diags.Warningf(fset.Position(comm.Slash+token.Pos(w.Offset)), "%s", w.Msg))
+	}
+	if err != nil {
+		offset := 0
+		if aErr, ok := err.(*AnnotationError); ok {
+			offset = aErr.Offset
+		}
+		return nil, diagnostics.Append(diags.Errorf(fset.Position(comm.Slash+token.Pos(offset)), "%s", err.Error()))
+	}
+
+	for _, a := range annotations {
+		if err := validateAnnotation(a, allowed); err != nil {
+			return nil, diagnostics.Append(diags.Errorf(fset.Position(comm.Slash+token.Pos(a.Offset)), "%s", err.Error()))
+		}
+	}
+	return annotations, diagnostics
+}
+
+func validateAnnotation(a *Annotation, allowed []string) error {
+	found := false
+	for _, name := range allowed {
+		if a.Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("unexpected annotation @%s.%s", annotationNamespace, a.Name)
+	}
+
+	if freeOptionsAnnotations[a.Name] {
+		return nil
+	}
+
+	for key, value := range a.Options {
+		valid := false
+		for _, opt := range annotationOptions[a.Name] {
+			if key == opt {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown option %q for annotation @%s.%s", key, annotationNamespace, a.Name)
+		}
+
+		switch key {
+		case OptionName, OptionModule:
+			if !pyIdentifierRe.MatchString(value) {
+				return fmt.Errorf("option %s=%q is not a valid python identifier", key, value)
+			}
+		case OptionGil:
+			if value != GilRelease && value != GilHold {
+				return fmt.Errorf("option %s=%q must be one of %q or %q", key, value, GilRelease, GilHold)
+			}
+		}
+	}
+	return nil
+}
+
+// parseAnnotations returns all the pygo annotations found in text.
+// An annotation starts either at the beginning of the comment, or after a space.
+// A @pygo which isn't followed by a '.' may be prose, e.g. "see @pygo docs",
+// so it isn't an annotation, and is only returned as a warning.
+func parseAnnotations(text string) ([]*Annotation, []*AnnotationError, error) {
+	annotations := []*Annotation{}
+	warnings := []*AnnotationError{}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || !annotationStart(text, i) {
+			continue
+		}
+
+		j := skipSpaces(text, i+1, false)
+		if !strings.HasPrefix(text[j:], annotationNamespace) {
+			continue
+		}
+		j += len(annotationNamespace)
+		if j < len(text) && isIdentChar(text[j]) {
+			// e.g. @pygoo
+			continue
+		}
+
+		if j >= len(text) || text[j] != '.' {
+			warnings = append(warnings, &AnnotationError{Offset: i, Msg: fmt.Sprintf("@%s isn't followed by '.', it's not an annotation", annotationNamespace)})
+			continue
+		}
+		j++
+
+		name := readIdent(text, j)
+		if name == "" {
+			return nil, warnings, &AnnotationError{Offset: j, Msg: "expected an annotation name"}
+		}
+		j += len(name)
+
+		a := &Annotation{
+			Name:    name,
+			Options: map[string]string{},
+			Offset:  i,
+		}
+
+		if j < len(text) && text[j] == '(' {
+			end, err := parseOptions(text, j+1, a.Options)
+			if err != nil {
+				return nil, warnings, err
+			}
+			j = end
+		}
+
+		annotations = append(annotations, a)
+		i = j - 1
+	}
+
+	return annotations, warnings, nil
+}
+
+// parseOptions parses `key=value, ...)` starting at i, and returns the offset
+// following the closing parenthesis.
+func parseOptions(text string, i int, opts map[string]string) (int, error) {
+	i = skipSpaces(text, i, true)
+	if i < len(text) && text[i] == ')' {
+		return i + 1, nil
+	}
+
+	for {
+		key := readIdent(text, i)
+		if key == "" {
+			return 0, &AnnotationError{Offset: i, Msg: "expected an option name"}
+		}
+		if _, ok := opts[key]; ok {
+			return 0, &AnnotationError{Offset: i, Msg: fmt.Sprintf("duplicate option %q", key)}
+		}
+		i = skipSpaces(text, i+len(key), true)
+
+		// an option without value is a flag
+		value := "true"
+		if i < len(text) && text[i] == '=' {
+			var err error
+			value, i, err = readValue(text, skipSpaces(text, i+1, true))
+			if err != nil {
+				return 0, err
+			}
+			i = skipSpaces(text, i, true)
+		}
+		opts[key] = value
+
+		if i >= len(text) {
+			return 0, &AnnotationError{Offset: i, Msg: "expected ')'"}
+		}
+		switch text[i] {
+		case ')':
+			return i + 1, nil
+		case ',':
+			i = skipSpaces(text, i+1, true)
+		default:
+			return 0, &AnnotationError{Offset: i, Msg: fmt.Sprintf("unexpected character %q, expected ',' or ')'", text[i])}
+		}
+	}
+}
+
+// readValue reads either a quoted go string or a bare word starting at i
+func readValue(text string, i int) (string, int, error) {
+	if i < len(text) && text[i] == '"' {
+		for j := i + 1; j < len(text); j++ {
+			if text[j] == '\\' {
+				j++
+				continue
+			}
+			if text[j] == '"' {
+				value, err := strconv.Unquote(text[i : j+1])
+				if err != nil {
+					return "", 0, &AnnotationError{Offset: i, Msg: fmt.Sprintf("invalid string %s: %v", text[i:j+1], err)}
+				}
+				return value, j + 1, nil
+			}
+			if text[j] == '\n' {
+				break
+			}
+		}
+		return "", 0, &AnnotationError{Offset: i, Msg: "unterminated string"}
+	}
+
+	j := i
+	for j < len(text) && (isIdentChar(text[j]) || strings.IndexByte(".-+", text[j]) >= 0) {
+		j++
+	}
+	if j == i {
+		return "", 0, &AnnotationError{Offset: i, Msg: "expected an option value"}
+	}
+	return text[i:j], j, nil
+}
+
+func annotationStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	if i == 2 && (text[:2] == "//" || text[:2] == "/*") {
+		return true
+	}
+	return isSpace(text[i-1])
+}
+
+func skipSpaces(text string, i int, newlines bool) int {
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t' || (newlines && isSpace(text[i]))) {
+		i++
+	}
+	return i
+}
+
+func readIdent(text string, i int) string {
+	j := i
+	for j < len(text) && isIdentChar(text[j]) {
+		j++
+	}
+	return text[i:j]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,221 @@
+package ast
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/diags"
+)
+
+func TestMain_parseAnnotations(t *testing.T) {
+
+	tests := []struct {
+		Text        string
+		Annotations []string
+		Warnings    int
+		Err         bool
+	}{
+		{
+			`//@pygo.export`,
+			[]string{"@pygo.export"},
+			0,
+			false,
+		},
+		{
+			`// @ pygo.export`,
+			[]string{"@pygo.export"},
+			0,
+			false,
+		},
+		{
+			`// @pygo.export(name="load_config", module=config, gil="release")`,
+			[]string{`@pygo.export(gil="release", module="config", name="load_config")`},
+			0,
+			false,
+		},
+		{
+			`// @pygo.export( name = "a\"b" , flag )`,
+			[]string{`@pygo.export(flag="true", name="a\"b")`},
+			0,
+			false,
+		},
+		{
+			`/* @pygo.export()
+ * @pygo.other
+ */`,
+			[]string{"@pygo.export", "@pygo.other"},
+			0,
+			false,
+		},
+		{
+			`// contact@pygo.org, @pygoo.export`,
+			[]string{},
+			0,
+			false,
+		},
+		{
+			`// @pygo.export(name="load_config"`,
+			nil,
+			0,
+			true,
+		},
+		{
+			`// @pygo.export(name=)`,
+			nil,
+			0,
+			true,
+		},
+		{
+			`// @pygo.export(name="a", name="b")`,
+			nil,
+			0,
+			true,
+		},
+		{
+			`// @pygo.export(name="a" module="b")`,
+			nil,
+			0,
+			true,
+		},
+		{
+			// prose isn't an annotation
+			`// @pygo export`,
+			[]string{},
+			1,
+			false,
+		},
+		{
+			`/* see the @pygo docs,
+ * @pygo.export
+ */`,
+			[]string{"@pygo.export"},
+			1,
+			false,
+		},
+		{
+			`/* this func is not exported
+ * @pygo.exporti
+ */`,
+			[]string{"@pygo.exporti"},
+			0,
+			false,
+		},
+		{
+			`/* this func is exported
+ * hi, @pygo.export, ok
+ */`,
+			[]string{"@pygo.export"},
+			0,
+			false,
+		},
+		{
+			`/* this func is not exported
+ * hi, -@pygo.export, ok
+ */`,
+			[]string{},
+			0,
+			false,
+		},
+		{
+			`/* this func is not exported
+ * hi, //@pygo.export, ok
+ */`,
+			[]string{},
+			0,
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			annotations, warnings, err := parseAnnotations(test.Text)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("an error was expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			res := []string{}
+			for _, a := range annotations {
+				res = append(res, a.String())
+			}
+			if fmt.Sprint(res) != fmt.Sprint(test.Annotations) {
+				t.Fatalf("match should be %v, was %v", test.Annotations, res)
+			}
+			if len(warnings) != test.Warnings {
+				t.Fatalf("match should be %v, was %v", test.Warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestMain_commentAnnotations(t *testing.T) {
+
+	tests := []struct {
+		Src string
+		Err string
+	}{
+		{
+			"package p\n\n// @pygo.export(name=\"ok\")\nfunc F() {}\n",
+			"",
+		},
+		{
+			"package p\n\n// hello\n// @pygo.export(name=\"load\", bad=1)\nfunc F() {}\n",
+			`p.go:4:4: unknown option "bad" for annotation @pygo.export`,
+		},
+		{
+			"package p\n\n// @pygo.export(name=\"load\"\nfunc F() {}\n",
+			`p.go:3:28: expected ')'`,
+		},
+		{
+			"package p\n\n// @pygo.export(gil=\"maybe\")\nfunc F() {}\n",
+			`p.go:3:4: option gil="maybe" must be one of "release" or "hold"`,
+		},
+		{
+			"package p\n\n// @pygo.export(name=\"not-valid\")\nfunc F() {}\n",
+			`p.go:3:4: option name="not-valid" is not a valid python identifier`,
+		},
+		{
+			"package p\n\n// @pygo.unknown\nfunc F() {}\n",
+			`p.go:3:4: unexpected annotation @pygo.unknown`,
+		},
+		{
+			"package p\n\n// F is documented in the @pygo README\nfunc F() {}\n",
+			`p.go:3:27: @pygo isn't followed by '.', it's not an annotation`,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", test.Src, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			var diag *diags.Diagnostic
+			for _, comm := range f.Comments[0].List {
+				if _, commDiags := commentAnnotations(fset, comm, AnnotationExport); len(commDiags) > 0 {
+					diag = commDiags[0]
+					break
+				}
+			}
+
+			if test.Err == "" {
+				if diag != nil {
+					t.Fatalf("%v", diag)
+				}
+				return
+			}
+			if diag == nil || !strings.HasSuffix(diag.Error(), test.Err) {
+				t.Fatalf("match should be %v, was %v", test.Err, diag)
+			}
+		})
+	}
+}
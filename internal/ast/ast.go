@@ -3,110 +3,406 @@ package ast
 import (
 	"fmt"
 	"go/ast"
+	"go/build"
+	"go/importer"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
-	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/yanndegat/pygo/internal/diags"
 )
 
 type AstFunc struct {
 	Name    string
 	Params  []*ast.Field
 	Results []*ast.Field
+	// Export is the @pygo.export annotation of the func
+	Export *Annotation
+	// Implicit is true if the func is exported by a package @pygo.exportall directive
+	Implicit bool
+	// Doc is the doc comment of the func, without pygo annotations
+	Doc string
+	// Defaults are the default values of the params, set with @pygo.defaults
+	Defaults map[string]string
+	// Pos is the position of the func name in the sources
+	Pos token.Position
+	// Object is the func resolved by the type checker, nil if it couldn't be
+	Object *types.Func
 }
 
 func (f *AstFunc) String() string {
 	return f.Name
 }
 
-func ParseDir(dir string) (map[string][]*AstFunc, error) {
-	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, goFiles, parser.ParseComments)
+// Override overrides the options of the export annotation of f, and the default values of its params,
+// e.g. with the settings of the config file. The options are validated like the annotation ones.
+func (f *AstFunc) Override(options, defaults map[string]string) error {
+	export := &Annotation{Name: AnnotationExport, Options: map[string]string{}}
+	if f.Export != nil {
+		export.Offset = f.Export.Offset
+		for key, value := range f.Export.Options {
+			export.Options[key] = value
+		}
+	}
+	for key, value := range options {
+		export.Options[key] = value
+	}
+	if err := validateAnnotation(export, []string{AnnotationExport}); err != nil {
+		return err
+	}
+	f.Export = export
+
+	if f.Defaults == nil {
+		f.Defaults = map[string]string{}
+	}
+	for name, value := range defaults {
+		f.Defaults[name] = value
+	}
+	return nil
+}
+
+type AstLib struct {
+	Funcs   []*AstFunc
+	Structs []*AstStruct
+	// ExportAll is true if the package doc has a @pygo.exportall directive
+	ExportAll bool
+	// Doc is the package doc comment, without pygo directives
+	Doc string
+	// Types is the type checked package
+	Types *types.Package
+}
+
+func (l *AstLib) String() string {
+	return fmt.Sprintf("funcs: %v, structs: %v", l.Funcs, l.Structs)
+}
+
+// ParsePackage parses and type checks a package listed by ListPackages.
+// The imported packages are resolved with the export data listed with it.
+func ParsePackage(pkg *Package, tags []string) (map[string]*AstLib, diags.Diagnostics) {
+	return parseDir(pkg.Dir, pkg.ImportPath, tags, pkg.importer)
+}
+
+// ParseDir parses the go files of dir which are part of the build
+// with the given build tags, the same way `go build` would.
+// The package is type checked, its imports being resolved from their sources.
+// All the problems found are returned as diagnostics.
+func ParseDir(dir string, tags []string) (map[string]*AstLib, diags.Diagnostics) {
+	return parseDir(dir, filepath.Base(dir), tags, func(fset *token.FileSet) types.Importer {
+		return importer.ForCompiler(fset, "source", nil)
+	})
+}
+
+func parseDir(dir, importPath string, tags []string, newImporter func(*token.FileSet) types.Importer) (map[string]*AstLib, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+
+	files, err := buildFiles(dir, tags)
 	if err != nil {
-		return nil, err
+		return nil, diagnostics.Append(err)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return files[info.Name()]
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, diagnostics.Append(err)
 	}
 	log.Printf("[INFO] %v scanned", dir)
 
-	pyLibs := map[string][]*AstFunc{}
-	for name, pkg := range pkgs {
+	// the packages and files are sorted, so that the generated files don't depend on the map order
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pyLibs := map[string]*AstLib{}
+	for _, name := range names {
+		pkg := pkgs[name]
 		log.Printf("[TRACE] Parsing pkg name %v", pkg.Name)
 
-		res, err := parsePkg(name, pkg)
-		if err != nil {
-			return nil, fmt.Errorf("[ERROR] parsing of pkg %s failed: %v", name, err)
-		}
+		tpkg, info, typeDiags := checkPkg(fset, importPath, pkg, newImporter(fset))
+		diagnostics = diagnostics.Append(typeDiags)
+
+		res, pkgDiags := parsePkg(fset, name, pkg, info)
+		diagnostics = diagnostics.Append(pkgDiags)
+		res.Types = tpkg
 
 		if pyLibs[name] == nil {
 			pyLibs[name] = res
 		} else {
-			pyLibs[name] = append(pyLibs[name], res...)
+			pyLibs[name].Funcs = append(pyLibs[name].Funcs, res.Funcs...)
+			pyLibs[name].Structs = append(pyLibs[name].Structs, res.Structs...)
+			pyLibs[name].ExportAll = pyLibs[name].ExportAll || res.ExportAll
+			pyLibs[name].Doc = joinDocs(pyLibs[name].Doc, res.Doc)
 		}
 	}
-	return pyLibs, nil
+	return pyLibs, diagnostics
+}
+
+// checkPkg type checks pkg. Type errors are reported as diagnostics,
+// the types which could be resolved are still returned.
+func checkPkg(fset *token.FileSet, importPath string, pkg *ast.Package, imp types.Importer) (*types.Package, *types.Info, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+
+	names := fileNames(pkg)
+	files := make([]*ast.File, len(names))
+	for i, name := range names {
+		files[i] = pkg.Files[name]
+	}
+
+	conf := types.Config{
+		Importer: imp,
+		// cgo isn't run, references to C are not checked
+		FakeImportC: true,
+		Error: func(err error) {
+			if tErr, ok := err.(types.Error); ok {
+				diagnostics = diagnostics.Append(diags.Errorf(tErr.Fset.Position(tErr.Pos), "%s", tErr.Msg))
+				return
+			}
+			diagnostics = diagnostics.Append(err)
+		},
+	}
+	info := &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+	}
+	// errors are collected by conf.Error
+	tpkg, _ := conf.Check(importPath, fset, files, info)
+	return tpkg, info, diagnostics
 }
 
-// Specify what files to parser
-func goFiles(info os.FileInfo) bool {
-	if strings.HasSuffix(info.Name(), ".go") {
-		return true
+// buildFiles returns the go files of dir matching the build context,
+// test files excluded.
+func buildFiles(dir string, tags []string) (map[string]bool, error) {
+	ctx := build.Default
+	ctx.BuildTags = tags
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't match build constraints of %s: %v", filepath.Join(dir, name), err)
+		}
+		if !match {
+			log.Printf("[DEBUG] file %s is excluded by build constraints", name)
+			continue
+		}
+		files[name] = true
 	}
-	return false
+	return files, nil
 }
 
-func parsePkg(name string, pkg *ast.Package) ([]*AstFunc, error) {
-	astFuncs := []*AstFunc{}
-	var err error
+// fileNames returns the sorted names of the files of pkg
+func fileNames(pkg *ast.Package) []string {
+	names := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	for filePath, f := range pkg.Files {
+func parsePkg(fset *token.FileSet, name string, pkg *ast.Package, info *types.Info) (*AstLib, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+	lib := &AstLib{
+		Funcs:   []*AstFunc{},
+		Structs: []*AstStruct{},
+	}
+
+	// package directives can be set in the package doc of any file
+	for _, filePath := range fileNames(pkg) {
+		f := pkg.Files[filePath]
+		annotations, annDiags := docAnnotations(fset, f.Doc, AnnotationExportAll)
+		diagnostics = diagnostics.Append(annDiags)
+		if annotations[AnnotationExportAll] != nil {
+			log.Printf("[DEBUG] all funcs of pkg %s are exported", name)
+			lib.ExportAll = true
+		}
+		lib.Doc = joinDocs(lib.Doc, docText(f.Doc))
+	}
+
+	for _, filePath := range fileNames(pkg) {
+		f := pkg.Files[filePath]
 		log.Printf("[DEBUG] Parsing file Name %v at %v", f.Name, filePath)
 		source := path.Base(filePath)
 
-		ast.Inspect(f, func(n ast.Node) bool {
-			// handle function declarations without documentation
-			if n != nil {
-				log.Printf("[TRACE] %s:%v %v", source, n.Pos(), reflect.TypeOf(n))
-			}
+		for _, decl := range f.Decls {
+			log.Printf("[TRACE] %s:%v %v", source, decl.Pos(), reflect.TypeOf(decl))
+
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				astFunc, funcDiags := parseFuncDecl(fset, d, lib.ExportAll)
+				diagnostics = diagnostics.Append(funcDiags)
+				if astFunc != nil {
+					astFunc.Object, _ = info.Defs[d.Name].(*types.Func)
+					log.Printf("[DEBUG] func %v is exported in %s", astFunc, name)
+					lib.Funcs = append(lib.Funcs, astFunc)
+				}
+
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
 
-			if fn, ok := n.(*ast.FuncDecl); ok {
-				if fn.Doc != nil && len(fn.Doc.List) > 0 {
-					log.Printf("[TRACE] func %s in %s is exported", source, fn.Name.Name)
-					for _, comm := range fn.Doc.List {
-						log.Printf("[TRACE] func %s in %s comment is %s", source, fn.Name.Name, comm.Text)
-						isExported, _err := commentFuncExport(comm.Text)
-						if _err != nil {
-							log.Printf("[ERROR] failed to parse %s/%s/%s : %v", source, fn.Name.Name, comm.Text, _err)
-							err = _err
-							return false
-						}
-
-						if isExported && ast.IsExported(fn.Name.Name) {
-							var results []*ast.Field
-							if fn.Type.Results != nil {
-								results = fn.Type.Results.List
-							}
-
-							astFunc := &AstFunc{
-								Name:    fn.Name.Name,
-								Params:  fn.Type.Params.List,
-								Results: results,
-							}
-							log.Printf("[DEBUG] func %v is exported in %s", astFunc, name)
-							astFuncs = append(astFuncs, astFunc)
-						}
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
 					}
 
+					// the doc of a single type declaration is attached to the GenDecl
+					doc := ts.Doc
+					if doc == nil && len(d.Specs) == 1 {
+						doc = d.Doc
+					}
+
+					astStruct, structDiags := parseTypeSpec(fset, ts, doc)
+					diagnostics = diagnostics.Append(structDiags)
+					if astStruct != nil {
+						resolveFields(astStruct, info.Defs[ts.Name])
+						log.Printf("[DEBUG] struct %v is exported in %s", astStruct, name)
+						lib.Structs = append(lib.Structs, astStruct)
+					}
 				}
 			}
-			return true
-		})
+		}
+	}
+
+	return lib, diagnostics
+}
+
+// parseFuncDecl returns the AstFunc of fn if it's exported,
+// either with an annotation, or because the package exports all its funcs.
+func parseFuncDecl(fset *token.FileSet, fn *ast.FuncDecl, exportAll bool) (*AstFunc, diags.Diagnostics) {
+	pos := fset.Position(fn.Name.Pos())
+
+	annotations, diagnostics := docAnnotations(fset, fn.Doc, AnnotationExport, AnnotationSkip, AnnotationDefaults)
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+
+	export, skip := annotations[AnnotationExport], annotations[AnnotationSkip]
+	if export != nil && skip != nil {
+		return nil, diagnostics.Append(diags.Errorf(pos, "func %s can't be both exported and skipped", fn.Name.Name))
+	}
+	if skip != nil {
+		log.Printf("[DEBUG] func %s is skipped", fn.Name.Name)
+		return nil, diagnostics
+	}
+
+	if export == nil {
+		if annotations[AnnotationDefaults] != nil {
+			diagnostics = diagnostics.Append(diags.Warningf(pos, "func %s has default values but isn't annotated with @pygo.export", fn.Name.Name))
+		}
+		// funcs implicitly exported by @pygo.exportall must be exportable
+		if !exportAll || !ast.IsExported(fn.Name.Name) || fn.Recv != nil {
+			return nil, diagnostics
+		}
+	} else {
+		if !ast.IsExported(fn.Name.Name) {
+			return nil, diagnostics.Append(diags.Errorf(pos, "func %s is annotated with @pygo.export but isn't exported", fn.Name.Name))
+		}
+		if fn.Recv != nil {
+			return nil, diagnostics.Append(diags.Errorf(pos, "method %s is annotated with @pygo.export but methods can't be exported", fn.Name.Name))
+		}
+	}
+
+	var results []*ast.Field
+	if fn.Type.Results != nil {
+		results = fn.Type.Results.List
+	}
+
+	astFunc := &AstFunc{
+		Name:     fn.Name.Name,
+		Params:   fn.Type.Params.List,
+		Results:  results,
+		Export:   export,
+		Implicit: export == nil,
+		Doc:      docText(fn.Doc),
+		Defaults: map[string]string{},
+		Pos:      pos,
+	}
+	if defaults := annotations[AnnotationDefaults]; defaults != nil {
+		astFunc.Defaults = defaults.Options
+	}
+	return astFunc, diagnostics
+}
+
+// parseTypeSpec returns the AstStruct of ts if it's an exported struct.
+func parseTypeSpec(fset *token.FileSet, ts *ast.TypeSpec, doc *ast.CommentGroup) (*AstStruct, diags.Diagnostics) {
+	pos := fset.Position(ts.Name.Pos())
+
+	annotations, diagnostics := docAnnotations(fset, doc, AnnotationExport)
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+	export := annotations[AnnotationExport]
+	if export == nil {
+		return nil, diagnostics
+	}
+
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return nil, diagnostics.Append(diags.Errorf(pos, "type %s is annotated with @pygo.export but only structs can be exported", ts.Name.Name))
+	}
+	if !ast.IsExported(ts.Name.Name) {
+		return nil, diagnostics.Append(diags.Errorf(pos, "struct %s is annotated with @pygo.export but isn't exported", ts.Name.Name))
+	}
+	if _, ok := export.Options[OptionGil]; ok {
+		return nil, diagnostics.Append(diags.Errorf(pos, "option %s is only valid on funcs", OptionGil))
 	}
 
-	return astFuncs, err
+	astStruct, structDiags := parseStruct(fset, ts.Name.Name, st)
+	diagnostics = diagnostics.Append(structDiags)
+	if structDiags.HasErrors() {
+		return nil, diagnostics
+	}
+	astStruct.Export = export
+	astStruct.Doc = docText(doc)
+	astStruct.Pos = pos
+	return astStruct, diagnostics
 }
 
-func commentFuncExport(text string) (bool, error) {
-	return regexp.MatchString(`(^|^//|[[:space:]])@(pygo)\.(export)($|\W)`, text)
+// docAnnotations returns the annotations found in doc, by name.
+// Only the allowed annotations are accepted, each at most once.
+func docAnnotations(fset *token.FileSet, doc *ast.CommentGroup, allowed ...string) (map[string]*Annotation, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+	res := map[string]*Annotation{}
+	if doc == nil {
+		return res, nil
+	}
+
+	for _, comm := range doc.List {
+		annotations, commDiags := commentAnnotations(fset, comm, allowed...)
+		diagnostics = diagnostics.Append(commDiags)
+		if commDiags.HasErrors() {
+			continue
+		}
+		for _, a := range annotations {
+			if res[a.Name] != nil {
+				diagnostics = diagnostics.Append(diags.Errorf(fset.Position(comm.Slash+token.Pos(a.Offset)), "duplicate annotation %v", a))
+				continue
+			}
+			res[a.Name] = a
+		}
+	}
+	return res, diagnostics
 }
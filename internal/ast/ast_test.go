@@ -2,10 +2,18 @@ package ast
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"runtime"
+	"sort"
 	"testing"
 )
 
-func TestMain_commentFuncExport(t *testing.T) {
+// the export annotation is found at the start of a comment, or after a space
+func TestMain_parseAnnotations_export(t *testing.T) {
 
 	tests := []struct {
 		Text  string
@@ -54,13 +62,244 @@ func TestMain_commentFuncExport(t *testing.T) {
 
 	for i, test := range tests {
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			match, err := commentFuncExport(test.Text)
+			annotations, _, err := parseAnnotations(test.Text)
 			if err != nil {
 				t.Fatalf("%v", err)
 			}
+			match := false
+			for _, a := range annotations {
+				match = match || a.Name == AnnotationExport
+			}
 			if match != test.Match {
 				t.Fatalf("match should be %v, was %v", test.Match, match)
 			}
 		})
 	}
 }
+
+func TestMain_parsePkg_exportAll(t *testing.T) {
+	src := `// Package p exports everything
+// @pygo.exportall
+package p
+
+func Exported(arg string) string { return arg }
+
+// @pygo.skip
+func Skipped(arg string) string { return arg }
+
+// @pygo.export(name="renamed")
+func Annotated(arg string) string { return arg }
+
+func unexported(arg string) string { return arg }
+
+type T struct{}
+
+func (t T) Method() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	lib, diagnostics := parsePkg(fset, "p", &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": f}}, &types.Info{})
+	if diagnostics.HasErrors() {
+		t.Fatalf("%v", diagnostics.Err())
+	}
+
+	if !lib.ExportAll {
+		t.Fatalf("lib should export all funcs")
+	}
+
+	shouldBe := "[Exported Annotated]"
+	if fmt.Sprint(lib.Funcs) != shouldBe {
+		t.Fatalf("match should be %v, was %v", shouldBe, lib.Funcs)
+	}
+	if !lib.Funcs[0].Implicit || lib.Funcs[1].Implicit {
+		t.Fatalf("only Exported should be implicitly exported")
+	}
+}
+
+func TestMain_parsePkg_skipExported(t *testing.T) {
+	src := `package p
+
+// @pygo.export
+// @pygo.skip
+func F() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, diagnostics := parsePkg(fset, "p", &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": f}}, &types.Info{})
+	if !diagnostics.HasErrors() {
+		t.Fatalf("an error was expected")
+	}
+}
+
+func TestMain_ParseDir_buildConstraints(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test data is linux specific")
+	}
+
+	tests := []struct {
+		Tags  []string
+		Funcs string
+	}{
+		{
+			nil,
+			"[A BLinux]",
+		},
+		{
+			[]string{"foo"},
+			"[A BLinux CFoo]",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			libs, diagnostics := ParseDir("testdata/buildtags", test.Tags)
+			if diagnostics.HasErrors() {
+				t.Fatalf("%v", diagnostics.Err())
+			}
+			if len(libs) != 1 || libs["buildtags"] == nil {
+				t.Fatalf("only lib buildtags should have been found, was %v", libs)
+			}
+
+			funcs := []string{}
+			for _, f := range libs["buildtags"].Funcs {
+				funcs = append(funcs, f.Name)
+			}
+			sort.Strings(funcs)
+			if fmt.Sprint(funcs) != test.Funcs {
+				t.Fatalf("match should be %v, was %v", test.Funcs, funcs)
+			}
+		})
+	}
+}
+
+func TestMain_parsePkg_diagnostics(t *testing.T) {
+	src := `package p
+
+// @pygo.export(nme="x")
+func A() {}
+
+// @pygo.export
+func b() {}
+
+// @pygo.export
+func (t T) M() {}
+
+// @pygo.export
+type T int
+
+// @pygo.export
+func Ok() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	lib, diagnostics := parsePkg(fset, "p", &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": f}}, &types.Info{})
+
+	shouldBe := []string{
+		`p.go:3:4: unknown option "nme" for annotation @pygo.export`,
+		`p.go:7:6: func b is annotated with @pygo.export but isn't exported`,
+		`p.go:10:12: method M is annotated with @pygo.export but methods can't be exported`,
+		`p.go:13:6: type T is annotated with @pygo.export but only structs can be exported`,
+	}
+	if len(diagnostics) != len(shouldBe) {
+		t.Fatalf("match should be %v, was %v", shouldBe, diagnostics)
+	}
+	for i, d := range diagnostics {
+		if d.Error() != shouldBe[i] {
+			t.Fatalf("match should be %v, was %v", shouldBe[i], d.Error())
+		}
+	}
+
+	if fmt.Sprint(lib.Funcs) != "[Ok]" {
+		t.Fatalf("match should be [Ok], was %v", lib.Funcs)
+	}
+}
+
+func TestMain_ParseDir_types(t *testing.T) {
+	libs, diagnostics := ParseDir("testdata/typed", nil)
+
+	// the type errors are reported, the other types are still resolved
+	shouldBe := "typed.go:19:15: error: undefined: Unknown"
+	if len(diagnostics) != 1 || diagnostics[0].Format("testdata/typed") != shouldBe {
+		t.Fatalf("match should be %v, was %v", shouldBe, diagnostics)
+	}
+
+	lib := libs["typed"]
+	if lib == nil || lib.Types == nil || lib.Types.Name() != "typed" {
+		t.Fatalf("lib typed should have been type checked, was %v", libs)
+	}
+
+	sigs := []string{}
+	for _, f := range lib.Funcs {
+		if f.Object == nil {
+			t.Fatalf("func %s should have been resolved", f.Name)
+		}
+		sigs = append(sigs, types.TypeString(f.Object.Type(), types.RelativeTo(lib.Types)))
+	}
+	sort.Strings(sigs)
+	if fmt.Sprint(sigs) != "[func() invalid type func(id ID) ID]" {
+		t.Fatalf("match should be %v, was %v", "[func() invalid type func(id ID) ID]", sigs)
+	}
+
+	fields := []string{}
+	for _, f := range lib.Structs[0].Fields {
+		fields = append(fields, types.TypeString(f.Resolved, types.RelativeTo(lib.Types)))
+	}
+	if fmt.Sprint(fields) != "[ID string]" {
+		t.Fatalf("match should be %v, was %v", "[ID string]", fields)
+	}
+}
+
+func TestMain_AstFunc_Override(t *testing.T) {
+	tests := []struct {
+		Export   *Annotation
+		Options  map[string]string
+		Defaults map[string]string
+		Expected map[string]string
+		Err      string
+	}{
+		{nil, map[string]string{OptionName: "load"}, nil, map[string]string{OptionName: "load"}, ""},
+		{
+			&Annotation{Name: AnnotationExport, Options: map[string]string{OptionName: "load", OptionGil: GilHold}},
+			map[string]string{OptionName: "fetch", OptionModule: "io"},
+			map[string]string{"n": "1"},
+			map[string]string{OptionName: "fetch", OptionModule: "io", OptionGil: GilHold},
+			"",
+		},
+		{nil, map[string]string{OptionGil: "maybe"}, nil, nil, `option gil="maybe" must be one of "release" or "hold"`},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			f := &AstFunc{Name: "Load", Export: test.Export, Defaults: map[string]string{"m": "2"}}
+			err := f.Override(test.Options, test.Defaults)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(f.Export.Options, test.Expected) {
+				t.Fatalf("match should be %v, was %v", test.Expected, f.Export.Options)
+			}
+			if len(f.Defaults) != 1+len(test.Defaults) {
+				t.Fatalf("match should be %v, was %v", 1+len(test.Defaults), len(f.Defaults))
+			}
+		})
+	}
+}
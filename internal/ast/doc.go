@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// docText returns the text of a doc comment, without comment markers,
+// directives, and pygo annotation lines.
+func docText(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+
+	lines := []string{}
+	for _, comm := range doc.List {
+		lines = append(lines, commentLines(comm.Text)...)
+	}
+
+	res := []string{}
+	for _, line := range lines {
+		if annotations, _, err := parseAnnotations(line); err != nil || len(annotations) > 0 {
+			continue
+		}
+		// collapse consecutive blank lines
+		if line == "" && (len(res) == 0 || res[len(res)-1] == "") {
+			continue
+		}
+		res = append(res, line)
+	}
+
+	return strings.TrimSpace(strings.Join(res, "\n"))
+}
+
+// commentLines returns the lines of a single comment, without comment markers.
+func commentLines(text string) []string {
+	if strings.HasPrefix(text, "//") {
+		text = text[2:]
+		// directives such as //go:generate aren't part of the doc,
+		// but //@pygo annotations are removed afterwards.
+		if len(text) > 0 && !isSpace(text[0]) && text[0] != '@' {
+			return nil
+		}
+		return []string{strings.TrimRight(strings.TrimPrefix(text, " "), " \t\r")}
+	}
+
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		// remove the decoration of block comments, e.g. ` * text`
+		if line == "*" {
+			line = ""
+		} else if strings.HasPrefix(line, "* ") {
+			line = line[2:]
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// joinDocs joins the non empty docs with a blank line
+func joinDocs(docs ...string) string {
+	res := []string{}
+	for _, doc := range docs {
+		if doc != "" {
+			res = append(res, doc)
+		}
+	}
+	return strings.Join(res, "\n\n")
+}
@@ -0,0 +1,52 @@
+package ast
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestMain_docText(t *testing.T) {
+
+	tests := []struct {
+		Comment string
+		Doc     string
+	}{
+		{
+			"// F does things.\n// @pygo.export",
+			"F does things.",
+		},
+		{
+			"//@pygo.export\n//go:noinline",
+			"",
+		},
+		{
+			"/* this func is exported\n * @pygo.export\n */",
+			"this func is exported",
+		},
+		{
+			"// F does things.\n//\n//\n// It does them well.\n// @pygo.export(name=\"f\")\n//",
+			"F does things.\n\nIt does them well.",
+		},
+		{
+			"/*\n * F does things.\n *\n * It does them well.\n */",
+			"F does things.\n\nIt does them well.",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			src := fmt.Sprintf("package p\n\n%s\nfunc F() {}\n", test.Comment)
+			f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+
+			doc := docText(f.Comments[0])
+			if doc != test.Doc {
+				t.Fatalf("match should be %q, was %q", test.Doc, doc)
+			}
+		})
+	}
+}
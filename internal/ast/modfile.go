@@ -4,19 +4,28 @@ import (
 	"fmt"
 	"golang.org/x/mod/modfile"
 	"io/ioutil"
+	"path/filepath"
 
 	"github.com/yanndegat/pygo/internal/utils"
 )
 
+// Mod is the module of a scanned package
 type Mod struct {
+	// Main is the path of the module, e.g. github.com/x/lib
 	Main string
+	// Path is the dir of the package relative to the root of the module, e.g. /pkg, empty for the root package
 	Path string
+	// Dir is the root dir of the module
+	Dir string
+	// GoMod is the path of the go.mod file of the module
+	GoMod string
 }
 
 func (m Mod) String() string {
 	return fmt.Sprintf("main: %s, path: %s", m.Main, m.Path)
 }
 
+// Import returns the import path of the package
 func (m Mod) Import() string {
 	if m.Path == "" {
 		return m.Main
@@ -29,6 +38,9 @@ func ParseMod(dir string) (*Mod, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't find go.mod: %v", err)
 	}
+	if goMod == "" {
+		return nil, fmt.Errorf("Couldn't find go.mod in %s or its parent dirs", dir)
+	}
 
 	goModText, err := ioutil.ReadFile(goMod)
 	if err != nil {
@@ -46,7 +58,9 @@ func ParseMod(dir string) (*Mod, error) {
 	}
 
 	return &Mod{
-		Main: mainMod,
-		Path: modPath,
+		Main:  mainMod,
+		Path:  modPath,
+		Dir:   filepath.Dir(goMod),
+		GoMod: goMod,
 	}, nil
 }
@@ -0,0 +1,162 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// Package is a go package matched by a package pattern
+type Package struct {
+	Dir        string
+	ImportPath string
+	Name       string
+	// Export is the file holding the export data of the compiled package
+	Export string
+	// DepOnly is true if the package is only listed as a dependency
+	DepOnly bool
+	// ImportMap maps the import paths of the sources to the actual packages, e.g. vendored ones
+	ImportMap map[string]string
+	// Deps are the import paths of the dependencies of the package, recursively
+	Deps []string
+	// LocalDeps are the dirs of the dependencies listed in a main module, by import path,
+	// i.e. the ones whose sources are edited along with the package
+	LocalDeps map[string]string `json:"-"`
+	GoFiles   []string
+	CgoFiles  []string
+	Module    *struct {
+		Main bool
+	}
+	Error *struct {
+		Err string
+	}
+
+	// exports maps the import paths of all the listed packages to their export data
+	exports map[string]string
+}
+
+func (p *Package) String() string {
+	return p.ImportPath
+}
+
+// importer returns a types importer which reads the compiled export data
+// of the dependencies of the package, as listed by `go list -export`.
+func (p *Package) importer(fset *token.FileSet) types.Importer {
+	return importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		if mapped, ok := p.ImportMap[path]; ok {
+			path = mapped
+		}
+		export := p.exports[path]
+		if export == "" {
+			return nil, fmt.Errorf("no export data for package %s", path)
+		}
+		return os.Open(export)
+	})
+}
+
+// BuildInputs returns the compiled files of the package and of its dependencies, as listed by `go list -export`.
+// The files of the build cache are named after their content, so they change with the sources of the packages.
+func (p *Package) BuildInputs() []string {
+	inputs := []string{}
+	for _, path := range append([]string{p.ImportPath}, p.Deps...) {
+		if export := p.exports[path]; export != "" {
+			inputs = append(inputs, fmt.Sprintf("%s %s", path, export))
+		}
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// ListPackages resolves package patterns, such as `./...` or import paths,
+// relatively to dir with a single `go list` run.
+// The dependencies are compiled by the same run, so that the packages can be type checked.
+// goBin is the go binary to run, defaulting to `go`, and env is added to its environment,
+// so that the packages are listed and compiled by the toolchain which builds them.
+func ListPackages(dir string, patterns []string, tags []string, goBin string, env []string) ([]*Package, error) {
+	return goList(dir, patterns, tags, goBin, env, "-deps", "-export")
+}
+
+// FindPackages resolves package patterns like ListPackages, without compiling them.
+func FindPackages(dir string, patterns []string, tags []string, goBin string, env []string) ([]*Package, error) {
+	return goList(dir, patterns, tags, goBin, env)
+}
+
+func goList(dir string, patterns []string, tags []string, goBin string, env []string, flags ...string) ([]*Package, error) {
+	args := append([]string{"list", "-e", "-json", fmt.Sprintf("-tags=%s", strings.Join(tags, ","))}, flags...)
+	args = append(args, patterns...)
+
+	var stdout bytes.Buffer
+	if goBin == "" {
+		goBin = "go"
+	}
+	cmd := exec.Command(goBin, args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = log.Writer()
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list %v failed in %s: %v", patterns, dir, err)
+	}
+
+	exports := map[string]string{}
+	localDirs := map[string]string{}
+	pkgs := []*Package{}
+	dec := json.NewDecoder(&stdout)
+	for {
+		pkg := &Package{exports: exports}
+		err := dec.Decode(pkg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't decode go list output: %v", err)
+		}
+
+		if pkg.Export != "" {
+			exports[pkg.ImportPath] = pkg.Export
+		}
+		if pkg.Module != nil && pkg.Module.Main {
+			localDirs[pkg.ImportPath] = pkg.Dir
+		}
+		if pkg.DepOnly {
+			continue
+		}
+
+		if pkg.Error != nil {
+			if pkg.Dir == "" {
+				return nil, fmt.Errorf("Couldn't list package %s: %s", pkg.ImportPath, pkg.Error.Err)
+			}
+			// e.g. a dir without any go file matching the build constraints
+			if len(pkg.GoFiles) == 0 && len(pkg.CgoFiles) == 0 {
+				log.Printf("[WARN] package %s is ignored: %s", pkg.ImportPath, pkg.Error.Err)
+				continue
+			}
+			// compile errors are reported by the type checker
+			log.Printf("[DEBUG] package %s has errors: %s", pkg.ImportPath, pkg.Error.Err)
+		}
+		log.Printf("[DEBUG] package %s found in %s", pkg.ImportPath, pkg.Dir)
+		pkgs = append(pkgs, pkg)
+	}
+
+	// the dependencies are only listed with -deps
+	for _, pkg := range pkgs {
+		pkg.LocalDeps = map[string]string{}
+		for _, dep := range pkg.Deps {
+			if dir, ok := localDirs[dep]; ok {
+				pkg.LocalDeps[dep] = dir
+			}
+		}
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,193 @@
+package ast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/diags"
+)
+
+const (
+	tagPygo = "pygo"
+	tagJson = "json"
+
+	tagOptOmit     = "omit"
+	tagOptReadOnly = "readonly"
+)
+
+type AstStruct struct {
+	Name   string
+	Fields []*AstField
+	// Export is the @pygo.export annotation of the struct
+	Export *Annotation
+	// Doc is the doc comment of the struct, without pygo annotations
+	Doc string
+	// Pos is the position of the struct name in the sources
+	Pos token.Position
+}
+
+func (s *AstStruct) String() string {
+	return s.Name
+}
+
+type AstField struct {
+	// Name is the go name of the field
+	Name string
+	// PyName is the name of the field on the python side
+	PyName   string
+	Type     ast.Expr
+	Omit     bool
+	ReadOnly bool
+	// Pos is the position of the field name in the sources
+	Pos token.Position
+	// Resolved is the type resolved by the type checker, nil if it couldn't be
+	Resolved types.Type
+}
+
+func (f *AstField) String() string {
+	return fmt.Sprintf("%s(%s)", f.Name, f.PyName)
+}
+
+func parseStruct(fset *token.FileSet, name string, st *ast.StructType) (*AstStruct, diags.Diagnostics) {
+	var diagnostics diags.Diagnostics
+	s := &AstStruct{
+		Name:   name,
+		Fields: []*AstField{},
+	}
+
+	for _, field := range st.Fields.List {
+		// embedded fields are not supported
+		if len(field.Names) == 0 {
+			diagnostics = diagnostics.Append(diags.Errorf(fset.Position(field.Pos()), "embedded field %v in struct %s is not supported", field.Type, name))
+			continue
+		}
+
+		tag := ""
+		if field.Tag != nil {
+			t, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				diagnostics = diagnostics.Append(diags.Errorf(fset.Position(field.Tag.Pos()), "Couldn't read tag of field %s in struct %s: %v", field.Names[0].Name, name, err))
+				continue
+			}
+			tag = t
+		}
+
+		for _, fieldName := range field.Names {
+			f, err := parseField(fieldName.Name, field.Type, tag)
+			if err != nil {
+				diagnostics = diagnostics.Append(diags.Errorf(fset.Position(fieldName.Pos()), "invalid field %s in struct %s: %v", fieldName.Name, name, err))
+				continue
+			}
+			f.Pos = fset.Position(fieldName.Pos())
+			s.Fields = append(s.Fields, f)
+		}
+	}
+
+	// the fields are the params of the constructor of the python class, so their names must be unique,
+	// once the python keywords are escaped
+	seen := map[string]*AstField{}
+	for _, f := range s.Fields {
+		if f.Omit {
+			continue
+		}
+		pyName := PyIdentifier(f.PyName)
+		if prev, ok := seen[pyName]; ok {
+			diagnostics = diagnostics.Append(diags.Errorf(f.Pos, "field %s of struct %s has the python name %s, which is already the one of field %s", f.Name, name, pyName, prev.Name))
+			continue
+		}
+		seen[pyName] = f
+	}
+
+	return s, diagnostics
+}
+
+// resolveFields sets the types of the fields of s, resolved by the type checker
+func resolveFields(s *AstStruct, obj types.Object) {
+	if obj == nil {
+		return
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+
+	resolved := map[string]types.Type{}
+	for i := 0; i < st.NumFields(); i++ {
+		resolved[st.Field(i).Name()] = st.Field(i).Type()
+	}
+	for _, f := range s.Fields {
+		f.Resolved = resolved[f.Name]
+	}
+}
+
+func parseField(name string, t ast.Expr, tag string) (*AstField, error) {
+	f := &AstField{
+		Name:   name,
+		PyName: name,
+		Type:   t,
+		// unexported fields are never visible from python
+		Omit: !ast.IsExported(name),
+	}
+
+	pyName, opts, err := parseStructTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if pyName == "-" {
+		f.Omit = true
+	} else if pyName != "" {
+		f.PyName = pyName
+	}
+
+	for _, opt := range opts {
+		switch opt {
+		case tagOptOmit:
+			f.Omit = true
+		case tagOptReadOnly:
+			f.ReadOnly = true
+		}
+	}
+
+	// the omitted fields aren't visible from python, whatever their name
+	if !f.Omit && !pyIdentifierRe.MatchString(f.PyName) {
+		return nil, fmt.Errorf("the name %q of its tag is not a valid python identifier", f.PyName)
+	}
+	return f, nil
+}
+
+// parseStructTag reads the `pygo:"name,omit,readonly"` struct tag. The name falls back to the one of
+// the `json` tag when the pygo tag has no name, e.g. `pygo:",readonly" json:"c_name"`, or when there's no pygo tag.
+// It's empty if neither tag has a name.
+func parseStructTag(tag string) (string, []string, error) {
+	structTag := reflect.StructTag(tag)
+
+	name := ""
+	var opts []string
+	if value, ok := structTag.Lookup(tagPygo); ok {
+		parts := strings.Split(value, ",")
+		name = strings.TrimSpace(parts[0])
+		opts = []string{}
+		for _, opt := range parts[1:] {
+			opt = strings.TrimSpace(opt)
+			switch opt {
+			case tagOptOmit, tagOptReadOnly:
+				opts = append(opts, opt)
+			default:
+				return "", nil, fmt.Errorf("unknown pygo tag option %q", opt)
+			}
+		}
+	}
+
+	if value, ok := structTag.Lookup(tagJson); ok && name == "" {
+		// json options such as omitempty have no meaning in python
+		name = strings.Split(value, ",")[0]
+	}
+
+	return name, opts, nil
+}
@@ -0,0 +1,179 @@
+package ast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestMain_parseStructTag(t *testing.T) {
+
+	tests := []struct {
+		Tag  string
+		Name string
+		Opts []string
+		Err  bool
+	}{
+		{
+			``,
+			"",
+			nil,
+			false,
+		},
+		{
+			`pygo:"a_string"`,
+			"a_string",
+			[]string{},
+			false,
+		},
+		{
+			`pygo:"id,readonly" json:"ID"`,
+			"id",
+			[]string{"readonly"},
+			false,
+		},
+		{
+			`pygo:",omit,readonly"`,
+			"",
+			[]string{"omit", "readonly"},
+			false,
+		},
+		{
+			`json:"an_int,omitempty"`,
+			"an_int",
+			nil,
+			false,
+		},
+		{
+			// the json name is the fallback of an empty pygo name
+			`pygo:",readonly" json:"c_name"`,
+			"c_name",
+			[]string{"readonly"},
+			false,
+		},
+		{
+			`pygo:",omit" json:",omitempty"`,
+			"",
+			[]string{"omit"},
+			false,
+		},
+		{
+			`pygo:"name,unknown"`,
+			"",
+			nil,
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			name, opts, err := parseStructTag(test.Tag)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("an error was expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if name != test.Name {
+				t.Fatalf("match should be %v, was %v", test.Name, name)
+			}
+			if fmt.Sprint(opts) != fmt.Sprint(test.Opts) {
+				t.Fatalf("match should be %v, was %v", test.Opts, opts)
+			}
+		})
+	}
+}
+
+func TestMain_parseStruct(t *testing.T) {
+	src := `package p
+
+type S struct {
+	AString  string ` + "`pygo:\"a_string\"`" + `
+	AnInt    int    ` + "`json:\"-\"`" + `
+	AnID     int    ` + "`pygo:\"id,readonly\"`" + `
+	internal string
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ts := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+
+	s, diagnostics := parseStruct(fset, ts.Name.Name, ts.Type.(*ast.StructType))
+	if diagnostics.HasErrors() {
+		t.Fatalf("%v", diagnostics.Err())
+	}
+
+	shouldBe := []AstField{
+		{Name: "AString", PyName: "a_string"},
+		{Name: "AnInt", PyName: "AnInt", Omit: true},
+		{Name: "AnID", PyName: "id", ReadOnly: true},
+		{Name: "internal", PyName: "internal", Omit: true},
+	}
+
+	if len(s.Fields) != len(shouldBe) {
+		t.Fatalf("match should be %v, was %v", shouldBe, s.Fields)
+	}
+	for i, f := range s.Fields {
+		if f.Name != shouldBe[i].Name || f.PyName != shouldBe[i].PyName ||
+			f.Omit != shouldBe[i].Omit || f.ReadOnly != shouldBe[i].ReadOnly {
+			t.Fatalf("match should be %v, was %v", shouldBe[i], *f)
+		}
+	}
+}
+
+func TestMain_parseStruct_pyNames(t *testing.T) {
+	tests := []struct {
+		Fields string
+		Err    string
+	}{
+		{
+			"A string `json:\"some-name\"`",
+			`p.go:4:2: error: invalid field A in struct S: the name "some-name" of its tag is not a valid python identifier`,
+		},
+		{
+			"A string `json:\"some-name\" pygo:\",omit\"`",
+			"",
+		},
+		{
+			"A string `pygo:\"class\"`",
+			"",
+		},
+		{
+			"A string `pygo:\"c\"`\n\tB string `pygo:\"c\"`",
+			`p.go:5:2: error: field B of struct S has the python name c, which is already the one of field A`,
+		},
+		{
+			"A string `pygo:\"class\"`\n\tB string `pygo:\"class_\"`",
+			`p.go:5:2: error: field B of struct S has the python name class_, which is already the one of field A`,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			src := "package p\n\ntype S struct {\n\t" + test.Fields + "\n}\n"
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", src, 0)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			ts := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec)
+
+			_, diagnostics := parseStruct(fset, ts.Name.Name, ts.Type.(*ast.StructType))
+			errStr := ""
+			if len(diagnostics) > 0 {
+				errStr = diagnostics[0].Format("")
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+		})
+	}
+}
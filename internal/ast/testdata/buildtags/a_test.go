@@ -0,0 +1,4 @@
+package buildtags_test
+
+// @pygo.export
+func ATest() {}
@@ -0,0 +1,4 @@
+package buildtags
+
+// @pygo.export
+func BWindows() {}
@@ -0,0 +1,7 @@
+//go:build foo
+// +build foo
+
+package buildtags
+
+// @pygo.export
+func CFoo() {}
@@ -0,0 +1,21 @@
+package typed
+
+import "strings"
+
+type ID string
+
+// @pygo.export
+type S struct {
+	ID   ID
+	Name string
+}
+
+// @pygo.export
+func Upper(id ID) ID {
+	return ID(strings.ToUpper(string(id)))
+}
+
+// @pygo.export
+func Broken() Unknown {
+	return nil
+}
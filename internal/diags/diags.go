@@ -0,0 +1,133 @@
+package diags
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found in the sources, located at Pos if it's valid.
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position
+	Summary  string
+}
+
+// Errorf returns an error diagnostic located at pos
+func Errorf(pos token.Position, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: Error,
+		Pos:      pos,
+		Summary:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Warningf returns a warning diagnostic located at pos
+func Warningf(pos token.Position, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: Warning,
+		Pos:      pos,
+		Summary:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Error formats the diagnostic as `file:line:col: summary`, the same way as go tools
+func (d *Diagnostic) Error() string {
+	if !d.Pos.IsValid() {
+		return d.Summary
+	}
+	return fmt.Sprintf("%v: %s", d.Pos, d.Summary)
+}
+
+// Format formats the diagnostic with its severity, and a file path relative to dir
+func (d *Diagnostic) Format(dir string) string {
+	pos := d.Pos
+	if rel, err := filepath.Rel(dir, pos.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+		pos.Filename = rel
+	}
+
+	if !pos.IsValid() {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Summary)
+	}
+	return fmt.Sprintf("%v: %s: %s", pos, d.Severity, d.Summary)
+}
+
+type Diagnostics []*Diagnostic
+
+// Append appends diagnostics, or errors which are converted to diagnostics.
+func (diags Diagnostics) Append(items ...interface{}) Diagnostics {
+	for _, item := range items {
+		switch i := item.(type) {
+		case nil:
+		case *Diagnostic:
+			if i != nil {
+				diags = append(diags, i)
+			}
+		case Diagnostics:
+			diags = append(diags, i...)
+		case scanner.ErrorList:
+			for _, err := range i {
+				diags = append(diags, Errorf(err.Pos, "%s", err.Msg))
+			}
+		case error:
+			diags = append(diags, &Diagnostic{Severity: Error, Summary: i.Error()})
+		default:
+			panic(fmt.Sprintf("can't append %T to diagnostics", item))
+		}
+	}
+	return diags
+}
+
+func (diags Diagnostics) HasErrors() bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error summarizing the error diagnostics, or nil
+func (diags Diagnostics) Err() error {
+	errs := []string{}
+	for _, d := range diags {
+		if d.Severity == Error {
+			errs = append(errs, d.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
+}
+
+// Sort sorts the diagnostics by position
+func (diags Diagnostics) Sort() {
+	sort.SliceStable(diags, func(i, j int) bool {
+		pi, pj := diags[i].Pos, diags[j].Pos
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Column < pj.Column
+	})
+}
@@ -0,0 +1,37 @@
+package diags
+
+import (
+	"fmt"
+	"go/token"
+	"testing"
+)
+
+func TestMain_Diagnostics_Format(t *testing.T) {
+	var diagnostics Diagnostics
+	diagnostics = diagnostics.Append(
+		Warningf(token.Position{Filename: "/src/pkg/b.go", Line: 1, Column: 2}, "warn %d", 1),
+		Errorf(token.Position{Filename: "/src/pkg/a.go", Line: 10, Column: 2}, "err %d", 2),
+		Errorf(token.Position{Filename: "/src/pkg/a.go", Line: 3, Column: 4}, "err %d", 3),
+		fmt.Errorf("no position"),
+	)
+	diagnostics.Sort()
+
+	shouldBe := []string{
+		"error: no position",
+		"pkg/a.go:3:4: error: err 3",
+		"pkg/a.go:10:2: error: err 2",
+		"pkg/b.go:1:2: warning: warn 1",
+	}
+	for i, d := range diagnostics {
+		if d.Format("/src") != shouldBe[i] {
+			t.Fatalf("match should be %v, was %v", shouldBe[i], d.Format("/src"))
+		}
+	}
+
+	if !diagnostics.HasErrors() {
+		t.Fatalf("diagnostics should have errors")
+	}
+	if diagnostics[3:].HasErrors() || diagnostics[3:].Err() != nil {
+		t.Fatalf("warnings aren't errors")
+	}
+}
@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines around the changes of a hunk
+const context = 3
+
+type op int
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+// edit is a line of a, deleted or kept, or a line of b, inserted
+type edit struct {
+	Op op
+	// A and B are the indexes of the line in a and b. Only A is set for a deletion, and B for an insertion.
+	A, B int
+}
+
+// Unified returns the unified diff between a and b, named aName and bName,
+// as printed by `diff -u`. It's empty if a and b are equal.
+func Unified(aName, bName string, a, b []byte) string {
+	aLines, bLines := splitLines(string(a)), splitLines(string(b))
+	edits := myers(aLines, bLines)
+
+	var sb strings.Builder
+	for _, h := range hunks(edits) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		aStart, aLen, bStart, bLen := h.ranges()
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, e := range h {
+			switch e.Op {
+			case opEqual:
+				writeLine(&sb, " ", aLines[e.A])
+			case opDelete:
+				writeLine(&sb, "-", aLines[e.A])
+			case opInsert:
+				writeLine(&sb, "+", bLines[e.B])
+			}
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits text in lines, which keep their line feed
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func writeLine(sb *strings.Builder, prefix, line string) {
+	sb.WriteString(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// hunkRange formats the range of a hunk, whose start is 1-based,
+// or is the line preceding the hunk if it's empty.
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+type hunk []edit
+
+// ranges returns the 0-based starts and the lengths of the hunk in a and b
+func (h hunk) ranges() (aStart, aLen, bStart, bLen int) {
+	aStart, bStart = -1, -1
+	for _, e := range h {
+		if e.Op != opInsert {
+			if aStart < 0 {
+				aStart = e.A
+			}
+			aLen++
+		}
+		if e.Op != opDelete {
+			if bStart < 0 {
+				bStart = e.B
+			}
+			bLen++
+		}
+	}
+
+	// an empty range starts at the line preceding the hunk
+	if aStart < 0 {
+		aStart = h[0].A
+	}
+	if bStart < 0 {
+		bStart = h[0].B
+	}
+	return
+}
+
+// hunks groups the changes with their context, overlapping contexts being merged
+func hunks(edits []edit) []hunk {
+	res := []hunk{}
+	start, end := -1, -1
+	for i, e := range edits {
+		if e.Op == opEqual {
+			continue
+		}
+		from, to := i-context, i+context+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		if start >= 0 {
+			res = append(res, hunk(edits[start:end]))
+		}
+		start, end = from, to
+	}
+	if start >= 0 {
+		res = append(res, hunk(edits[start:end]))
+	}
+	return res
+}
+
+// myers returns the shortest edit script from a to b, computed with the Myers diff algorithm.
+// Deletions and insertions hold the position of the other side, which hunks use for empty ranges.
+func myers(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+
+	trace := [][]int{}
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// walk the trace backwards, from the end of a and b
+	edits := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{Op: opEqual, A: x, B: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{Op: opInsert, A: x, B: prevY})
+			} else {
+				edits = append(edits, edit{Op: opDelete, A: prevX, B: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	// reverse the edits
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
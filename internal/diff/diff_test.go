@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_Unified(t *testing.T) {
+	tests := []struct {
+		A    string
+		B    string
+		Diff string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "", ""},
+		{"", "a\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\n", "", "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n"},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"a\nb", "a\nb\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\n2\nx\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			"--- a\n+++ b\n@@ -1,5 +1,6 @@\n 1\n 2\n+x\n 3\n 4\n 5\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n",
+			"1\n2\n4\n5\n6\n7\n",
+			"--- a\n+++ b\n@@ -1,6 +1,6 @@\n 1\n 2\n-3\n 4\n 5\n 6\n+7\n",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			d := Unified("a", "b", []byte(test.A), []byte(test.B))
+			if d != test.Diff {
+				t.Fatalf("match should be %q, was %q", test.Diff, d)
+			}
+		})
+	}
+}
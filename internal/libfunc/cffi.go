@@ -0,0 +1,68 @@
+package libfunc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CffiDecl returns the declaration of the cgo wrapper in the cdef of a cffi build script.
+// The params are unnamed, as their go names can be C keywords.
+func (f *Func) CffiDecl() string {
+	params := make([]string, len(f.Args))
+	for i, a := range f.Args {
+		params[i] = a.CType()
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	return fmt.Sprintf("%s %s(%s);", f.CResultType(), f.Name, strings.Join(params, ", "))
+}
+
+// CffiNeedsArgs returns true if the args of the python func are converted by a gocffi.Args,
+// which keeps their C buffers alive during the call
+func (f *Func) CffiNeedsArgs() bool {
+	for _, a := range f.Args {
+		if a.Type.IsArray() || a.Type == TypeString {
+			return true
+		}
+	}
+	return false
+}
+
+// CffiValue returns the value passed to the cgo wrapper for the arg in python:
+// strings and slices are converted by the gocffi.Args `_args`, and the other values are passed as is
+func (a Arg) CffiValue() string {
+	switch {
+	case a.Type.IsArray() && a.Type.T() == TypeString:
+		return fmt.Sprintf("_args.strings(%s)", a.PyName())
+	case a.Type.IsArray():
+		return fmt.Sprintf("_args.slice(%q, %s)", a.CElemType(), a.PyName())
+	case a.Type == TypeString:
+		return fmt.Sprintf("_args.string(%s)", a.PyName())
+	}
+	return a.PyName()
+}
+
+// CffiResult returns the call of the cgo wrapper by the python func, its result being converted to python
+// and freed by the gocffi converters
+func (f *Func) CffiResult() string {
+	args := make([]string, len(f.Args))
+	for i, a := range f.Args {
+		args[i] = a.CffiValue()
+	}
+	call := fmt.Sprintf("_lib.%s(%s)", f.Name, strings.Join(args, ", "))
+
+	switch {
+	case f.IsVoid():
+		return call
+	case f.Result.IsArray() && f.Result.T() == TypeBool:
+		return fmt.Sprintf("gocffi.from_slice(_ffi, _lib, %s, %q, bool)", call, cGoTypes[f.Result.T()])
+	case f.Result.IsArray():
+		return fmt.Sprintf("gocffi.from_slice(_ffi, _lib, %s, %q)", call, cGoTypes[f.Result.T()])
+	case f.Result == TypeString || f.Result == TypeError:
+		return fmt.Sprintf("gocffi.from_%s(_ffi, _lib, %s)", f.Result, call)
+	case f.Result == TypeBool:
+		return fmt.Sprintf("bool(%s)", call)
+	}
+	return call
+}
@@ -0,0 +1,66 @@
+package libfunc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_Func_Cffi(t *testing.T) {
+	tests := []struct {
+		Func      Func
+		Decl      string
+		NeedsArgs bool
+		Result    string
+	}{
+		{
+			Func{Name: "F", PyName: "F", Result: TypeVoid},
+			"void F(void);",
+			false,
+			"_lib.F()",
+		},
+		{
+			Func{Name: "F", PyName: "f", Args: []Arg{{Name: "from", Type: TypeString}, {Name: "n", Type: TypeInt}}, Result: TypeString},
+			"char* F(GoString, GoInt);",
+			true,
+			"gocffi.from_string(_ffi, _lib, _lib.F(_args.string(from_), n))",
+		},
+		{
+			Func{Name: "F", PyName: "F", Args: []Arg{{Name: "b", Type: TypeByte}, {Name: "ok", Type: TypeBool}}, Result: "[]int"},
+			"CSliceP F(GoUint8, GoUint8);",
+			false,
+			`gocffi.from_slice(_ffi, _lib, _lib.F(b, ok), "GoInt")`,
+		},
+		{
+			Func{Name: "F", PyName: "F", Args: []Arg{{Name: "s", Type: "[]string"}, {Name: "l", Type: "[]bool"}}, Result: "[]bool"},
+			"CSliceP F(GoSlice, GoSlice);",
+			true,
+			`gocffi.from_slice(_ffi, _lib, _lib.F(_args.strings(s), _args.slice("GoUint8", l)), "GoUint8", bool)`,
+		},
+		{
+			Func{Name: "F", PyName: "F", Args: []Arg{{Name: "n", Type: TypeInt32}}, Result: TypeError},
+			"char* F(GoInt32);",
+			false,
+			"gocffi.from_error(_ffi, _lib, _lib.F(n))",
+		},
+		{
+			Func{Name: "F", PyName: "F", Result: TypeBool},
+			"GoUint8 F(void);",
+			false,
+			"bool(_lib.F())",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if decl := test.Func.CffiDecl(); decl != test.Decl {
+				t.Fatalf("match should be %q, was %q", test.Decl, decl)
+			}
+			if needsArgs := test.Func.CffiNeedsArgs(); needsArgs != test.NeedsArgs {
+				t.Fatalf("match should be %v, was %v", test.NeedsArgs, needsArgs)
+			}
+			if result := test.Func.CffiResult(); result != test.Result {
+				t.Fatalf("match should be %q, was %q", test.Result, result)
+			}
+		})
+	}
+}
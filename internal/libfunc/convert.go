@@ -3,32 +3,149 @@ package libfunc
 import (
 	"fmt"
 	"go/ast"
+	"go/types"
 	"reflect"
+	"sort"
 
 	iast "github.com/yanndegat/pygo/internal/ast"
 )
 
-func ConvertFromAstF(lib string, astF *iast.AstFunc) (*Func, error) {
+// ConvertFromAstF converts an exported func. The named types with a converter are exchanged with python
+// as the type of their converter.
+func ConvertFromAstF(lib string, astF *iast.AstFunc, converters Converters) (*Func, error) {
 	if astF == nil {
 		return nil, nil
 	}
 
+	f := newFunc(lib, astF)
+	// the types resolved by the type checker are preferred to the ast ones
+	if astF.Object != nil {
+		if err := convertSignature(f, astF.Object, converters); err != nil {
+			return nil, err
+		}
+	} else if err := convertAstSignature(f, astF); err != nil {
+		return nil, err
+	}
+	if err := checkArgNames(f); err != nil {
+		return nil, err
+	}
+
+	if err := setDefaults(f, astF.Defaults); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// DeclaredFunc returns the func of a declaration which isn't exported, e.g. to list it with the reason why.
+// Its args and its result are the ones of the declaration, as far as they could be converted.
+func DeclaredFunc(lib string, astF *iast.AstFunc, converters Converters) *Func {
+	f := newFunc(lib, astF)
+	// the args and the result converted before an error are kept
+	if astF.Object != nil {
+		_ = convertSignature(f, astF.Object, converters)
+	} else {
+		_ = convertAstSignature(f, astF)
+	}
+	return f
+}
+
+// newFunc returns the func of astF, with the options of its annotation, without args nor result
+func newFunc(lib string, astF *iast.AstFunc) *Func {
 	f := &Func{
-		Lib:    lib,
-		Name:   astF.Name,
-		Args:   []Arg{},
-		Result: TypeVoid,
+		Lib:     lib,
+		Name:    astF.Name,
+		Args:    []Arg{},
+		Result:  TypeVoid,
+		PyName:  astF.Name,
+		Module:  lib,
+		Doc:     astF.Doc,
+		Pos:     astF.Pos,
+		Imports: map[string]string{},
+	}
+
+	if astF.Export != nil {
+		if name, ok := astF.Export.Options[iast.OptionName]; ok {
+			f.PyName = name
+		}
+		if module, ok := astF.Export.Options[iast.OptionModule]; ok {
+			f.Module = module
+		}
+		f.HoldGil = astF.Export.Options[iast.OptionGil] == iast.GilHold
+	}
+	return f
+}
+
+// convertSignature sets the args and the result of f from the signature of fn.
+// Those of an unsupported signature are set too, as they're declared.
+func convertSignature(f *Func, fn *types.Func, converters Converters) error {
+	sig := fn.Type().(*types.Signature)
+	qualifier := importQualifier(fn.Pkg(), f.Imports)
+	// the go types in the sources are only displayed, their packages aren't imported
+	sourceQualifier := func(p *types.Package) string { return p.Name() }
+
+	params := sig.Params()
+	for i := 0; i < params.Len(); i++ {
+		param := params.At(i)
+
+		// unnamed params are named after their position
+		name := param.Name()
+		if name == "" || name == "_" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+
+		t, goType, err := convertType(param.Type(), fn.Pkg(), qualifier, converters, true)
+		sourceType := types.TypeString(param.Type(), sourceQualifier)
+		if sig.Variadic() && i == params.Len()-1 {
+			sourceType = "..." + types.TypeString(param.Type().(*types.Slice).Elem(), sourceQualifier)
+		}
+		f.Args = append(f.Args, Arg{Name: name, Type: t, GoType: goType, SourceType: sourceType, unsupported: err})
+	}
+
+	results := sig.Results()
+	if results.Len() > 1 {
+		f.Result = Type(types.TypeString(results, sourceQualifier))
+		f.ResultSourceType = string(f.Result)
+		return fmt.Errorf("exported func can have 0 or 1 value returned.")
+	}
+	if results.Len() == 1 {
+		var goName string
+		f.Result, goName, f.resultUnsupported = convertType(results.At(0).Type(), fn.Pkg(), qualifier, converters, false)
+		f.ResultSourceType = types.TypeString(results.At(0).Type(), sourceQualifier)
+		// a converted result is converted by a func, and a named one by a type conversion
+		if c, _ := converters.lookup(results.At(0).Type()); c != nil {
+			f.ResultConv = goName
+		} else {
+			f.ResultGoType = goName
+		}
 	}
 
+	if sig.Variadic() {
+		return fmt.Errorf("variadic funcs are not supported")
+	}
+	return nil
+}
+
+// convertAstSignature sets the args and the result of f from the ast of the func,
+// when its types couldn't be resolved.
+func convertAstSignature(f *Func, astF *iast.AstFunc) error {
 	if astF.Params != nil {
 		for _, param := range astF.Params {
 			t, err := astTypeToType(param.Type)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
+			// unnamed params are named after their position
+			if len(param.Names) == 0 {
+				f.Args = append(f.Args, Arg{Name: fmt.Sprintf("arg%d", len(f.Args)), Type: *t, SourceType: string(*t)})
+			}
 			for i := 0; i < len(param.Names); i++ {
-				f.Args = append(f.Args, Arg{Name: param.Names[i].Name, Type: *t})
+				name := param.Names[i].Name
+				if name == "_" {
+					name = fmt.Sprintf("arg%d", len(f.Args))
+				}
+				f.Args = append(f.Args, Arg{Name: name, Type: *t, SourceType: string(*t)})
 			}
 		}
 	}
@@ -37,7 +154,7 @@ func ConvertFromAstF(lib string, astF *iast.AstFunc) (*Func, error) {
 		for _, result := range astF.Results {
 			t, err := astTypeToType(result.Type)
 			if err != nil {
-				return nil, err
+				return err
 			}
 
 			nbRets := len(result.Names)
@@ -47,19 +164,70 @@ func ConvertFromAstF(lib string, astF *iast.AstFunc) (*Func, error) {
 			}
 
 			if nbRets > 1 {
-				return nil, fmt.Errorf("exported func can have 0 or 1 return value.")
+				return fmt.Errorf("exported func can have 0 or 1 return value.")
 			}
 
 			// if f.Result is set to something else than TypeVoid, it's because we have more
 			// than 1 elt in astF.Results.
 			if f.Result != TypeVoid {
-				return nil, fmt.Errorf("exported func can have 0 or 1 value returned.")
+				return fmt.Errorf("exported func can have 0 or 1 value returned.")
 			}
 			f.Result = *t
+			f.ResultSourceType = string(*t)
 		}
 	}
 
-	return f, nil
+	return nil
+}
+
+// checkArgNames checks that the python names of the args of f are unique,
+// as a param named after a python keyword is renamed, e.g. class to class_.
+func checkArgNames(f *Func) error {
+	names := map[string]string{}
+	for _, a := range f.Args {
+		if prev, ok := names[a.PyName()]; ok {
+			return fmt.Errorf("params %s and %s are both named %s in python", prev, a.Name, a.PyName())
+		}
+		names[a.PyName()] = a.Name
+	}
+	return nil
+}
+
+// setDefaults sets the default values of the args of f
+func setDefaults(f *Func, defaults map[string]string) error {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := defaults[name]
+		found := false
+		for i, arg := range f.Args {
+			if arg.Name != name {
+				continue
+			}
+			found = true
+
+			lit, err := pyLiteral(arg.Type, value)
+			if err != nil {
+				return fmt.Errorf("invalid default value for param %s of func %s: %v", name, f.Name, err)
+			}
+			f.Args[i].Default = lit
+		}
+		if !found {
+			return fmt.Errorf("func %s has no param %s to set a default value for", f.Name, name)
+		}
+	}
+
+	// python requires params with a default value to be the last ones
+	for i := 1; i < len(f.Args); i++ {
+		if f.Args[i-1].Default != "" && f.Args[i].Default == "" {
+			return fmt.Errorf("param %s of func %s must have a default value, as param %s has one", f.Args[i].Name, f.Name, f.Args[i-1].Name)
+		}
+	}
+	return nil
 }
 
 func checkType(t interface{}) (bool, error) {
@@ -92,8 +260,6 @@ func checkType(t interface{}) (bool, error) {
 	} else {
 		return false, fmt.Errorf("unsupported ast type: %v", reflect.TypeOf(t))
 	}
-
-	return false, nil
 }
 
 func astTypeToType(t interface{}) (*Type, error) {
@@ -0,0 +1,149 @@
+package libfunc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cGoTypes are the C types of the go types in the header generated by cgo
+var cGoTypes = map[Type]string{
+	TypeBool:   "GoUint8",
+	TypeByte:   "GoUint8",
+	TypeInt:    "GoInt",
+	TypeInt32:  "GoInt32",
+	TypeInt64:  "GoInt64",
+	TypeString: "GoString",
+}
+
+// CSupportError returns the reason why f can't be called by a C extension module, if it can't.
+// It's only relevant for the funcs which are supported.
+func (f *Func) CSupportError() error {
+	for _, a := range f.Args {
+		if a.Type.T() == TypeError {
+			return fmt.Errorf("param %s of type %s can't be passed by a C extension module", a.Name, a.Type)
+		}
+	}
+	return nil
+}
+
+// CType returns the C type of the param of the cgo wrapper
+func (a Arg) CType() string {
+	if a.Type.IsArray() {
+		return "GoSlice"
+	}
+	return cGoTypes[a.Type]
+}
+
+// CElemType returns the C type of the elements of a slice param
+func (a Arg) CElemType() string {
+	return cGoTypes[a.Type.T()]
+}
+
+// CResultType returns the C type of the result of the cgo wrapper, void if there's none
+func (f *Func) CResultType() string {
+	switch {
+	case f.IsVoid():
+		return "void"
+	case f.Result.IsArray():
+		return "CSliceP"
+	case f.Result == TypeString || f.Result == TypeError:
+		return "char*"
+	}
+	return cGoTypes[f.Result]
+}
+
+// CKwList returns the python names of the params, as the kwlist of PyArg_ParseTupleAndKeywords
+func (f *Func) CKwList() string {
+	names := []string{}
+	for _, a := range f.Args {
+		names = append(names, fmt.Sprintf("%q", a.PyName()))
+	}
+	return strings.Join(append(names, "NULL"), ", ")
+}
+
+// CParseFormat returns the format of PyArg_ParseTupleAndKeywords: the strings are parsed as utf-8 buffers,
+// the slices as python objects, and the other params by the pygo_to_<type> converters.
+func (f *Func) CParseFormat() string {
+	var b strings.Builder
+	for _, a := range f.Args {
+		switch {
+		case a.Type.IsArray():
+			b.WriteString("O")
+		case a.Type == TypeString:
+			b.WriteString("s#")
+		default:
+			b.WriteString("O&")
+		}
+	}
+	return fmt.Sprintf("%s:%s", b.String(), f.PyName)
+}
+
+// CParseArgs returns the pointers set by PyArg_ParseTupleAndKeywords, after its kwlist
+func (f *Func) CParseArgs() string {
+	var b strings.Builder
+	for _, a := range f.Args {
+		switch {
+		case a.Type.IsArray():
+			fmt.Fprintf(&b, ", &py_%s", a.Name)
+		case a.Type == TypeString:
+			fmt.Fprintf(&b, ", &go_%s.p, &len_%s", a.Name, a.Name)
+		default:
+			fmt.Fprintf(&b, ", pygo_to_%s, &go_%s", a.Type, a.Name)
+		}
+	}
+	return b.String()
+}
+
+// CCallArgs returns the args of the call of the cgo wrapper
+func (f *Func) CCallArgs() string {
+	args := make([]string, len(f.Args))
+	for i, a := range f.Args {
+		args[i] = "go_" + a.Name
+	}
+	return strings.Join(args, ", ")
+}
+
+// CResult returns the python object of the result of the cgo wrapper, which frees it
+func (f *Func) CResult() string {
+	switch {
+	case f.IsVoid():
+		return "pygo_none()"
+	case f.Result.IsArray():
+		return fmt.Sprintf("pygo_from_slice(res, sizeof(%s), pygo_from_%s)", cGoTypes[f.Result.T()], f.Result.T())
+	case f.Result == TypeString || f.Result == TypeError:
+		return fmt.Sprintf("pygo_from_%s(res)", f.Result)
+	}
+	return fmt.Sprintf("pygo_from_%s(&res)", f.Result)
+}
+
+// CDoc returns the docstring of the func as a C string literal, NULL if it has none
+func (f *Func) CDoc() string {
+	if f.Doc == "" {
+		return "NULL"
+	}
+	return cString(f.Doc)
+}
+
+// cString returns the C string literal of s. The bytes which aren't printable ASCII are escaped in octal,
+// as hex escapes don't end after two digits in C.
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
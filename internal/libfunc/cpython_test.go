@@ -0,0 +1,98 @@
+package libfunc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_Func_CParse(t *testing.T) {
+	tests := []struct {
+		Func        Func
+		Format      string
+		ParseArgs   string
+		KwList      string
+		ResultType  string
+		Result      string
+		Unsupported bool
+	}{
+		{
+			Func{Name: "F", PyName: "F", Result: TypeVoid},
+			":F",
+			"",
+			"NULL",
+			"void",
+			"pygo_none()",
+			false,
+		},
+		{
+			Func{Name: "F", PyName: "f", Args: []Arg{{Name: "from", Type: TypeString}, {Name: "n", Type: TypeInt}, {Name: "l", Type: "[]bool"}}, Result: TypeString},
+			"s#O&O:f",
+			", &go_from.p, &len_from, pygo_to_int, &go_n, &py_l",
+			`"from_", "n", "l", NULL`,
+			"char*",
+			"pygo_from_string(res)",
+			false,
+		},
+		{
+			Func{Name: "F", PyName: "F", Args: []Arg{{Name: "b", Type: TypeByte}}, Result: "[]int"},
+			"O&:F",
+			", pygo_to_byte, &go_b",
+			`"b", NULL`,
+			"CSliceP",
+			"pygo_from_slice(res, sizeof(GoInt), pygo_from_int)",
+			false,
+		},
+		{
+			Func{Name: "F", PyName: "F", Args: []Arg{{Name: "err", Type: TypeError}}, Result: TypeInt32},
+			"O&:F",
+			", pygo_to_error, &go_err",
+			`"err", NULL`,
+			"GoInt32",
+			"pygo_from_int32(&res)",
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if format := test.Func.CParseFormat(); format != test.Format {
+				t.Fatalf("match should be %q, was %q", test.Format, format)
+			}
+			if args := test.Func.CParseArgs(); args != test.ParseArgs {
+				t.Fatalf("match should be %q, was %q", test.ParseArgs, args)
+			}
+			if kwList := test.Func.CKwList(); kwList != test.KwList {
+				t.Fatalf("match should be %q, was %q", test.KwList, kwList)
+			}
+			if resultType := test.Func.CResultType(); resultType != test.ResultType {
+				t.Fatalf("match should be %q, was %q", test.ResultType, resultType)
+			}
+			if result := test.Func.CResult(); result != test.Result {
+				t.Fatalf("match should be %q, was %q", test.Result, result)
+			}
+			if err := test.Func.CSupportError(); (err != nil) != test.Unsupported {
+				t.Fatalf("match should be %v, was %v", test.Unsupported, err)
+			}
+		})
+	}
+}
+
+func TestMain_cString(t *testing.T) {
+	tests := []struct {
+		S     string
+		Match string
+	}{
+		{"", `""`},
+		{"Hello greets name.\n\nIt's \"exported\".", `"Hello greets name.\n\nIt's \"exported\"."`},
+		{`a\b`, `"a\\b"`},
+		{"é\x01", `"\303\251\001"`},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if s := cString(test.S); s != test.Match {
+				t.Fatalf("match should be %v, was %v", test.Match, s)
+			}
+		})
+	}
+}
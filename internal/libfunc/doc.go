@@ -0,0 +1,35 @@
+package libfunc
+
+import (
+	"strings"
+)
+
+// pyDocstring formats doc as a python docstring, indented with indent
+func pyDocstring(doc, indent string) string {
+	if doc == "" {
+		return ""
+	}
+
+	doc = strings.ReplaceAll(doc, `\`, `\\`)
+	doc = strings.ReplaceAll(doc, `"""`, `\"\"\"`)
+
+	lines := strings.Split(doc, "\n")
+	if len(lines) == 1 {
+		// a quote before the closing quotes would end the docstring early
+		if strings.HasSuffix(doc, `"`) {
+			doc = doc[:len(doc)-1] + `\"`
+		}
+		return indent + `"""` + doc + `"""`
+	}
+
+	var b strings.Builder
+	b.WriteString(indent + `"""` + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if line != "" {
+			b.WriteString(indent + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + `"""`)
+	return b.String()
+}
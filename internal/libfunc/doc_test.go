@@ -0,0 +1,30 @@
+package libfunc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_pyDocstring(t *testing.T) {
+	tests := []struct {
+		Doc    string
+		Indent string
+		Match  string
+	}{
+		{"", "", ""},
+		{"Hello says hi", "", `"""Hello says hi"""`},
+		{`Hello says "hi"`, "", `"""Hello says "hi\""""`},
+		{`Hello says "hi"`, "    ", `    """Hello says "hi\""""`},
+		{`a path C:\`, "", `"""a path C:\\"""`},
+		{`quotes """ inside`, "", `"""quotes \"\"\" inside"""`},
+		{"Hello says\n\"hi\"", "", "\"\"\"Hello says\n\"hi\"\n\"\"\""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if doc := pyDocstring(test.Doc, test.Indent); doc != test.Match {
+				t.Fatalf("match should be %q, was %q", test.Match, doc)
+			}
+		})
+	}
+}
@@ -2,44 +2,144 @@ package libfunc
 
 import (
 	"fmt"
+	"go/token"
 	"strings"
+
+	iast "github.com/yanndegat/pygo/internal/ast"
 )
 
+// Func is an exported go func, which is wrapped by a cgo func in the go file of its lib,
+// and by a python func in a module of its lib. It's the data of the templates of the funcs.
 type Func struct {
-	Lib    string
-	Name   string
-	Args   []Arg
+	// Lib is the name of the go package of the func
+	Lib string
+	// Name is the name of the go func, and of its cgo wrapper
+	Name string
+	Args []Arg
+	// Result is the type of the result of the cgo wrapper, void if there's none
 	Result Type
+	// PyName is the name of the func in the python module
+	PyName string
+	// Module is the python module the func is generated in
+	Module string
+	// SharedLib is the file name of the shared lib the func is exported by
+	SharedLib string
+	// Static is true if the lib is a static archive, linked in the host process of python
+	Static bool
+	// HoldGil is true if the GIL must not be released during the call
+	HoldGil bool
+	// RaisesErrors is true if a returned error is raised by the python func, instead of being returned
+	RaisesErrors bool
+	// Doc is the doc comment of the go func
+	Doc string
+	// Pos is the position of the go func in the sources
+	Pos token.Position
+	// ResultGoType is the named go type of the result, converted to Result, if any
+	ResultGoType string
+	// ResultSourceType is the go type of the result in the sources, e.g. model.Date, empty if there's none
+	ResultSourceType string
+	// ResultConv is the func converting the result to Result, if its type has a converter
+	ResultConv string
+	// Imports are the names of the packages the go types of the func refer to, by import path
+	Imports map[string]string
+
+	// resultUnsupported is the reason why the result can't be returned to python
+	resultUnsupported error
 }
 
 func (f Func) IsSupported() bool {
+	return f.SupportError() == nil
+}
+
+// SupportError returns the reason why f is not supported, if it's not.
+func (f Func) SupportError() error {
 	for _, a := range f.Args {
-		if !supportedType(a.Type) {
-			return false
+		if err := a.SupportError(); err != nil {
+			return err
 		}
 	}
 
-	return supportedType(f.Result)
+	if f.resultUnsupported != nil {
+		return fmt.Errorf("returned type is not supported: %v", f.resultUnsupported)
+	}
+
+	// returned slices are copied in C memory, which can't hold go strings
+	if f.Result.IsArray() && f.Result.T().ToCType() == TypeCCharP {
+		return fmt.Errorf("returned type %s is not supported", f.Result)
+	}
+
+	if !supportedType(f.Result) {
+		return fmt.Errorf("returned type %s is not supported", f.Result)
+	}
+	return nil
 }
 
 func (f *Func) String() string {
 	return fmt.Sprintf("%s.%s: %v -> %v", f.Lib, f.Name, f.Args, f.Result)
 }
 
+// PyDoc returns the docstring of the python func
+func (f *Func) PyDoc() string {
+	return pyDocstring(f.Doc, "    ")
+}
+
+// PyDecoratorArgs returns the args of the gofunc python decorator
+func (f *Func) PyDecoratorArgs() string {
+	args := fmt.Sprintf(`lib="%s", sig="%s"`, f.SharedLib, f.PyTypeSig())
+	if f.PyName != f.Name {
+		args = fmt.Sprintf(`%s, fname="%s"`, args, f.Name)
+	}
+	if f.HoldGil {
+		args = fmt.Sprintf(`%s, gil="%s"`, args, iast.GilHold)
+	}
+	if f.Static {
+		args = fmt.Sprintf(`%s, static=True`, args)
+	}
+	return args
+}
+
+// PySig returns the params of the python func, with their default values
 func (f *Func) PySig() string {
-	sig := []string{}
+	sig := make([]string, len(f.Args))
 	for i, arg := range f.Args {
-		sig = append(sig, fmt.Sprintf("%s_%d", arg.Type.ToPyType(), i))
+		sig[i] = arg.PyName()
+		if arg.Default != "" {
+			sig[i] = fmt.Sprintf("%s=%s", sig[i], arg.Default)
+		}
+	}
+	return strings.Join(sig, ", ")
+}
+
+// HasDefaults returns true if a param of the python func has a default value
+func (f *Func) HasDefaults() bool {
+	for _, arg := range f.Args {
+		if arg.Default != "" {
+			return true
+		}
 	}
-	if f.Result != TypeVoid {
-		// star means kwargs, which is a special case
-		// interpreted by pygo to infer return type
-		sig = append(sig, fmt.Sprintf("*%s", f.Result.ToPyType()))
+	return false
+}
+
+// PyArgs returns the names of the params of the python func, e.g. to pass them on
+func (f *Func) PyArgs() string {
+	args := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = arg.PyName()
 	}
+	return strings.Join(args, ", ")
+}
 
-	return strings.Join(sig, ", ")
+// PyTypeSig returns the types of the args and of the result of the go func,
+// as expected by the `sig` arg of the gofunc python decorator.
+func (f *Func) PyTypeSig() string {
+	sig := []string{}
+	for _, arg := range f.Args {
+		sig = append(sig, string(arg.Type.ToPyType()))
+	}
+	return strings.Join(append(sig, string(f.Result.ToPyType())), ",")
 }
 
+// GoSigArgs returns the params of the cgo wrapper, e.g. `arg1 string, arg2 int`
 func (f *Func) GoSigArgs() string {
 	sig := make([]string, len(f.Args))
 	for i, arg := range f.Args {
@@ -48,6 +148,7 @@ func (f *Func) GoSigArgs() string {
 	return strings.Join(sig, ", ")
 }
 
+// GoFuncCall returns the call of the go func by the cgo wrapper, its args being converted to their go types
 func (f *Func) GoFuncCall() string {
 	args := make([]string, len(f.Args))
 	for i, arg := range f.Args {
@@ -57,31 +158,39 @@ func (f *Func) GoFuncCall() string {
 	return fmt.Sprintf("%s.%s(%s)", f.Lib, f.Name, strings.Join(args, ", "))
 }
 
+// ResultCall returns the call of the go func, its result being converted
+// from its named go type, or by its converter, if needed.
+func (f *Func) ResultCall() string {
+	if f.ResultConv != "" {
+		return fmt.Sprintf("%s(%s)", f.ResultConv, f.GoFuncCall())
+	}
+	if f.ResultGoType == "" {
+		return f.GoFuncCall()
+	}
+	return fmt.Sprintf("%s(%s)", f.Result, f.GoFuncCall())
+}
+
+// ReturnConvertedResult returns the statement returning the result of the go func, converted to its C type.
+// The slices are copied in C memory by the res_to_slice.go.tmpl template instead.
 func (f *Func) ReturnConvertedResult() string {
-	if f.IsVoid() {
+	if f.IsVoid() || f.Result.IsArray() {
 		return ""
 	}
 	if f.Result == TypeError {
-		return fmt.Sprintf("return handleError(%s)", f.GoFuncCall())
+		return fmt.Sprintf("return handleError(%s)", f.ResultCall())
 	}
 	if f.Result == TypeString {
-		return fmt.Sprintf("return C.CString(%s)", f.GoFuncCall())
-	}
-	if f.Result.IsArray() {
-		convert, err := f.convertResToSlice()
-		if err != nil {
-			return fmt.Sprintf("This is an impl error and shouldn't happen: %v", err)
-		}
-		return convert
+		return fmt.Sprintf("return C.CString(%s)", f.ResultCall())
 	}
-
-	return fmt.Sprintf("return %s", f.GoFuncCall())
+	return fmt.Sprintf("return %s", f.ResultCall())
 }
 
+// IsVoid returns true if the func returns nothing
 func (f *Func) IsVoid() bool {
 	return f.Result == TypeVoid
 }
 
+// GoSigRet returns the result type of the cgo wrapper, empty if it's void
 func (f *Func) GoSigRet() string {
 	if f.Result == TypeVoid {
 		return ""
@@ -90,15 +199,45 @@ func (f *Func) GoSigRet() string {
 	return string(f.Result.ToCType())
 }
 
+// Arg is a param of an exported func
 type Arg struct {
+	// Name is the name of the go param
 	Name string
+	// Type is the type of the param of the cgo wrapper
 	Type Type
+	// Default is the python literal of the default value of the arg, if any
+	Default string
+	// GoType is the named go type of the param, converted from Type, if any,
+	// or the func converting the param to its go type if it has a converter
+	GoType string
+	// SourceType is the go type of the param in the sources, e.g. model.Date
+	SourceType string
+
+	// unsupported is the reason why the arg can't be passed from python
+	unsupported error
+}
+
+// PyName returns the name of the arg in python, which can't be a python keyword
+func (a Arg) PyName() string {
+	return iast.PyIdentifier(a.Name)
+}
+
+// SupportError returns the reason why a can't be passed from python, if it can't.
+func (a Arg) SupportError() error {
+	if a.unsupported != nil {
+		return fmt.Errorf("param %s is not supported: %v", a.Name, a.unsupported)
+	}
+	if !supportedType(a.Type) {
+		return fmt.Errorf("param %s of type %s is not supported", a.Name, a.Type)
+	}
+	return nil
 }
 
 func (a Arg) String() string {
 	return fmt.Sprintf("%s:%s", a.Name, a.Type)
 }
 
+// ToGoValue returns the arg converted to the type of the go param
 func (a Arg) ToGoValue() string {
 	if a.Type == TypeCCharP {
 		return fmt.Sprintf("C.GoString(%s)", a.Name)
@@ -107,5 +246,8 @@ func (a Arg) ToGoValue() string {
 	if a.Type == TypeError {
 		return fmt.Sprintf("StringToError(%s)", a.Name)
 	}
+	if a.GoType != "" {
+		return fmt.Sprintf("%s(%s)", a.GoType, a.Name)
+	}
 	return a.Name
 }
@@ -0,0 +1,89 @@
+package libfunc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMain_Func_PySig(t *testing.T) {
+
+	tests := []struct {
+		Func      Func
+		Defaults  map[string]string
+		PySig     string
+		PyTypeSig string
+		Err       bool
+	}{
+		{
+			Func{Name: "F", Result: TypeVoid},
+			nil,
+			"",
+			"void",
+			false,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "arg1", Type: TypeString}, {Name: "arg2", Type: "[]int"}}, Result: TypeString},
+			nil,
+			"arg1, arg2",
+			"string,arr_int,string",
+			false,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "from", Type: TypeString}, {Name: "n", Type: TypeInt}, {Name: "b", Type: TypeBool}}, Result: TypeVoid},
+			map[string]string{"n": "0x10", "b": "true"},
+			"from_, n=16, b=True",
+			"string,int,bool,void",
+			false,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "s", Type: TypeString}}, Result: TypeVoid},
+			map[string]string{"s": "a \"quoted\"\nstring"},
+			`s="a \"quoted\"\nstring"`,
+			"string,void",
+			false,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "a", Type: TypeInt}, {Name: "b", Type: TypeInt}}, Result: TypeVoid},
+			map[string]string{"a": "1"},
+			"",
+			"",
+			true,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "a", Type: TypeInt}}, Result: TypeVoid},
+			map[string]string{"a": "one"},
+			"",
+			"",
+			true,
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "a", Type: TypeInt}}, Result: TypeVoid},
+			map[string]string{"b": "1"},
+			"",
+			"",
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			f := test.Func
+			err := setDefaults(&f, test.Defaults)
+			if test.Err {
+				if err == nil {
+					t.Fatalf("an error was expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if f.PySig() != test.PySig {
+				t.Fatalf("match should be %v, was %v", test.PySig, f.PySig())
+			}
+			if f.PyTypeSig() != test.PyTypeSig {
+				t.Fatalf("match should be %v, was %v", test.PyTypeSig, f.PyTypeSig())
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package libfunc
+
+import (
+	"fmt"
+	"go/types"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	errorType = types.Universe.Lookup("error").Type()
+
+	// majorVersionRe matches the major version suffix of an import path, e.g. /v2
+	majorVersionRe = regexp.MustCompile(`/v[0-9]+$`)
+)
+
+// Converter converts a named go type from and to a type exchanged with python,
+// e.g. for the named types which can't be converted with a type conversion.
+type Converter struct {
+	// Type is the type exchanged with python
+	Type Type
+	// ToGo is the func converting a value of Type to the named type, e.g. github.com/x/model.ParseDate
+	ToGo string
+	// FromGo is the func converting a value of the named type to Type, e.g. github.com/x/model.FormatDate
+	FromGo string
+}
+
+// Converters are the converters of named types, by qualified name, e.g. github.com/x/model.Date
+type Converters map[string]*Converter
+
+// ValidConverterType returns an error if t can't be the type of a converter,
+// i.e. if it's not a supported type, or a slice of one.
+func ValidConverterType(t Type) error {
+	if t == TypeVoid || t.IsPointer() || !supportedType(t) {
+		supported := []string{}
+		for _, T := range SupportedTypes {
+			if T != TypeVoid {
+				supported = append(supported, string(T))
+			}
+		}
+		return fmt.Errorf("%s is not supported, it must be one of %s, or a slice of them", t, strings.Join(supported, ", "))
+	}
+	return nil
+}
+
+// lookup returns the converter of t and its qualified name, if t is a named type with a converter
+func (c Converters) lookup(t types.Type) (*Converter, string) {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil, ""
+	}
+	key := fmt.Sprintf("%s.%s", named.Obj().Pkg().Path(), named.Obj().Name())
+	return c[key], key
+}
+
+// convertType converts t like fromGoType, unless it's a named type with a converter.
+// The returned go name is then the func converting values to t if toGo is set, or from t otherwise.
+func convertType(t types.Type, pkg *types.Package, qualifier types.Qualifier, converters Converters, toGo bool) (Type, string, error) {
+	c, key := converters.lookup(t)
+	if c == nil {
+		return fromGoType(t, qualifier)
+	}
+
+	fn, direction := c.FromGo, "from_go"
+	if toGo {
+		fn, direction = c.ToGo, "to_go"
+	}
+	if fn == "" {
+		return c.Type, "", fmt.Errorf("the converter of %s has no %s func", key, direction)
+	}
+	name, err := converterFunc(fn, pkg, qualifier)
+	if err != nil {
+		return c.Type, "", fmt.Errorf("the converter of %s is invalid: %v", key, err)
+	}
+	return c.Type, name, nil
+}
+
+// converterFunc returns the qualified go name of a converter func, e.g. model.ParseDate.
+// The package of the func is recorded by the qualifier if it must be imported.
+func converterFunc(fn string, pkg *types.Package, qualifier types.Qualifier) (string, error) {
+	i := strings.LastIndex(fn, ".")
+	if i <= 0 || i == len(fn)-1 {
+		return "", fmt.Errorf("func %q must be an import path and a func name, e.g. github.com/x/model.ParseDate", fn)
+	}
+	importPath, name := fn[:i], fn[i+1:]
+
+	// the packages imported by pkg have their actual name
+	fnPkg := types.NewPackage(importPath, path.Base(majorVersionRe.ReplaceAllString(importPath, "")))
+	if pkg != nil {
+		if pkg.Path() == importPath {
+			fnPkg = pkg
+		}
+		for _, imp := range pkg.Imports() {
+			if imp.Path() == importPath {
+				fnPkg = imp
+			}
+		}
+	}
+	return fmt.Sprintf("%s.%s", qualifier(fnPkg), name), nil
+}
+
+// fromGoType converts a type resolved by the type checker to the type exchanged with python.
+// If t is a named type, its qualified go name is returned too, as values must be converted
+// from and to it. The error is the reason why t can't be exchanged with python.
+func fromGoType(t types.Type, qualifier types.Qualifier) (Type, string, error) {
+	name := types.TypeString(t, qualifier)
+	if types.Identical(t, errorType) {
+		return TypeError, "", nil
+	}
+
+	goName := ""
+	// named types and aliases differ from their underlying type
+	if t != t.Underlying() {
+		goName = name
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		if u.Kind() == types.Invalid {
+			return Type(name), "", fmt.Errorf("its type couldn't be resolved")
+		}
+		res := basicType(u)
+		if !supportedType(res) {
+			if goName != "" {
+				return Type(name), "", fmt.Errorf("%s is a %s, which is not supported", name, u.Name())
+			}
+			return res, "", fmt.Errorf("%s is not supported", name)
+		}
+		return res, goName, nil
+
+	case *types.Slice:
+		elem, elemGoName, err := fromGoType(u.Elem(), qualifier)
+		if err != nil {
+			return Type(name), "", err
+		}
+		// a slice can't be converted to a slice of another element type
+		if elemGoName != "" {
+			return Type(name), "", fmt.Errorf("%s is a slice of the named type %s", name, elemGoName)
+		}
+		return Type(fmt.Sprintf("[]%s", elem)), goName, nil
+
+	case *types.Struct:
+		return Type(name), "", fmt.Errorf("%s is a struct", name)
+	case *types.Pointer:
+		return Type(name), "", fmt.Errorf("%s is a pointer", name)
+	case *types.Interface:
+		return Type(name), "", fmt.Errorf("%s is an interface", name)
+	case *types.Map:
+		return Type(name), "", fmt.Errorf("%s is a map", name)
+	case *types.Chan:
+		return Type(name), "", fmt.Errorf("%s is a channel", name)
+	case *types.Signature:
+		return Type(name), "", fmt.Errorf("%s is a func", name)
+	case *types.Array:
+		return Type(name), "", fmt.Errorf("%s is an array, only slices are supported", name)
+	}
+	return Type(name), "", fmt.Errorf("%s is not supported", name)
+}
+
+// basicType returns the Type of a basic go type, e.g. `byte` for `uint8`
+func basicType(b *types.Basic) Type {
+	switch b.Kind() {
+	case types.Bool:
+		return TypeBool
+	case types.Int:
+		return TypeInt
+	case types.Int32:
+		return TypeInt32
+	case types.Int64:
+		return TypeInt64
+	case types.Uint8:
+		return TypeByte
+	case types.String:
+		return TypeString
+	}
+	return Type(b.Name())
+}
+
+// importQualifier qualifies the types by the name of their package,
+// and records the import paths of the packages other than pkg.
+func importQualifier(pkg *types.Package, imports map[string]string) types.Qualifier {
+	return func(p *types.Package) string {
+		if p != pkg {
+			imports[p.Path()] = p.Name()
+		}
+		return p.Name()
+	}
+}
@@ -0,0 +1,279 @@
+package libfunc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	iast "github.com/yanndegat/pygo/internal/ast"
+)
+
+func TestMain_fromGoType(t *testing.T) {
+	src := `package p
+
+type ID string
+type IDs []int
+type Temp float64
+type S struct{}
+type I interface{ M() }
+
+var (
+	vString string
+	vByte   uint8
+	vError  error
+	vID     ID
+	vIDs    IDs
+	vSlice  []int
+	vTemp   Temp
+	vFloat  float64
+	vS      S
+	vPtr    *string
+	vI      I
+	vMap    map[string]int
+	vIDList []ID
+	vArray  [2]int
+)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	pkg, err := (&types.Config{}).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+
+	tests := []struct {
+		Var    string
+		Type   Type
+		GoType string
+		Err    string
+	}{
+		{"vString", TypeString, "", ""},
+		{"vByte", TypeByte, "", ""},
+		{"vError", TypeError, "", ""},
+		{"vID", TypeString, "p.ID", ""},
+		{"vIDs", "[]int", "p.IDs", ""},
+		{"vSlice", "[]int", "", ""},
+		{"vTemp", "p.Temp", "", "p.Temp is a float64, which is not supported"},
+		{"vFloat", "float64", "", "float64 is not supported"},
+		{"vS", "p.S", "", "p.S is a struct"},
+		{"vPtr", "*string", "", "*string is a pointer"},
+		{"vI", "p.I", "", "p.I is an interface"},
+		{"vMap", "map[string]int", "", "map[string]int is a map"},
+		{"vIDList", "[]p.ID", "", "[]p.ID is a slice of the named type p.ID"},
+		{"vArray", "[2]int", "", "[2]int is an array, only slices are supported"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			imports := map[string]string{}
+			typ, goType, err := fromGoType(pkg.Scope().Lookup(test.Var).Type(), importQualifier(pkg, imports))
+			if typ != test.Type {
+				t.Fatalf("match should be %v, was %v", test.Type, typ)
+			}
+			if goType != test.GoType {
+				t.Fatalf("match should be %v, was %v", test.GoType, goType)
+			}
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %v, was %v", test.Err, errStr)
+			}
+			if len(imports) != 0 {
+				t.Fatalf("match should be %v, was %v", map[string]string{}, imports)
+			}
+		})
+	}
+}
+
+func TestMain_convertType(t *testing.T) {
+	src := `package p
+
+type Date struct{}
+type Temp float64
+
+var (
+	vDate Date
+	vTemp Temp
+	vInt  int
+)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	pkg, err := (&types.Config{}).Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+
+	converters := Converters{
+		"p.Date": {Type: TypeString, ToGo: "p.ParseDate", FromGo: "p.FormatDate"},
+		"p.Temp": {Type: TypeString, ToGo: "example.com/units/v2.ParseTemp"},
+	}
+
+	tests := []struct {
+		Var     string
+		ToGo    bool
+		Type    Type
+		GoName  string
+		Imports map[string]string
+		Err     string
+	}{
+		{"vDate", true, TypeString, "p.ParseDate", map[string]string{}, ""},
+		{"vDate", false, TypeString, "p.FormatDate", map[string]string{}, ""},
+		{"vTemp", true, TypeString, "units.ParseTemp", map[string]string{"example.com/units/v2": "units"}, ""},
+		{"vTemp", false, TypeString, "", map[string]string{}, "the converter of p.Temp has no from_go func"},
+		{"vInt", true, TypeInt, "", map[string]string{}, ""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			imports := map[string]string{}
+			typ, goName, err := convertType(pkg.Scope().Lookup(test.Var).Type(), pkg, importQualifier(pkg, imports), converters, test.ToGo)
+			if typ != test.Type {
+				t.Fatalf("match should be %v, was %v", test.Type, typ)
+			}
+			if goName != test.GoName {
+				t.Fatalf("match should be %v, was %v", test.GoName, goName)
+			}
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %v, was %v", test.Err, errStr)
+			}
+			if !reflect.DeepEqual(imports, test.Imports) {
+				t.Fatalf("match should be %v, was %v", test.Imports, imports)
+			}
+		})
+	}
+}
+
+func TestMain_ConvertFromAstF_SourceType(t *testing.T) {
+	src := `package p
+
+import "time"
+
+type ID string
+
+func F(id ID, d time.Duration, s []string, e error) *ID { return nil }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+
+	fn, err := ConvertFromAstF("p", &iast.AstFunc{Name: "F", Object: pkg.Scope().Lookup("F").(*types.Func)}, nil)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	sourceTypes := []string{}
+	for _, a := range fn.Args {
+		sourceTypes = append(sourceTypes, a.SourceType)
+	}
+	if expected := []string{"p.ID", "time.Duration", "[]string", "error"}; !reflect.DeepEqual(sourceTypes, expected) {
+		t.Fatalf("match should be %v, was %v", expected, sourceTypes)
+	}
+	if expected := "*p.ID"; fn.ResultSourceType != expected {
+		t.Fatalf("match should be %v, was %v", expected, fn.ResultSourceType)
+	}
+}
+
+func TestMain_ConvertFromAstF_argNames(t *testing.T) {
+	tests := []struct {
+		Src string
+		Err string
+	}{
+		{"package p\n\nfunc F(class string, class_ int) {}\n", "params class and class_ are both named class_ in python"},
+		{"package p\n\nfunc F(_ string, arg0 int) {}\n", "params arg0 and arg0 are both named arg0 in python"},
+		{"package p\n\nfunc F(class string, from int) {}\n", ""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", test.Src, 0)
+			if err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+			pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/p", fset, []*ast.File{f}, nil)
+			if err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+
+			_, err = ConvertFromAstF("p", &iast.AstFunc{Name: "F", Object: pkg.Scope().Lookup("F").(*types.Func)}, nil)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+		})
+	}
+}
+
+func TestMain_DeclaredFunc(t *testing.T) {
+	src := `package p
+
+type User struct{}
+
+func Load(u User, name string) (int, error) { return 0, nil }
+
+func Join(sep string, s ...string) string { return "" }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	pkg, err := (&types.Config{Importer: importer.Default()}).Check("example.com/p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	astParams := func(name string) []*ast.Field {
+		return f.Scope.Lookup(name).Decl.(*ast.FuncDecl).Type.Params.List
+	}
+
+	tests := []struct {
+		AstF   *iast.AstFunc
+		Args   string
+		Result string
+	}{
+		// the args are converted even if the func isn't supported
+		{&iast.AstFunc{Name: "Load", Object: pkg.Scope().Lookup("Load").(*types.Func)}, "[u:p.User name:string]", "(int, error)"},
+		{&iast.AstFunc{Name: "Join", Object: pkg.Scope().Lookup("Join").(*types.Func)}, "[sep:string s:...string]", "string"},
+		// without the types, the args are converted from the ast until one can't be
+		{&iast.AstFunc{Name: "Join", Params: astParams("Join")}, "[sep:string]", ""},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			fn := DeclaredFunc("p", test.AstF, nil)
+			args := []string{}
+			for _, a := range fn.Args {
+				args = append(args, fmt.Sprintf("%s:%s", a.Name, a.SourceType))
+			}
+			if fmt.Sprint(args) != test.Args || fn.ResultSourceType != test.Result {
+				t.Fatalf("match should be %v %q, was %v %q", test.Args, test.Result, args, fn.ResultSourceType)
+			}
+		})
+	}
+}
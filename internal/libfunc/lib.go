@@ -0,0 +1,159 @@
+package libfunc
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Lib struct {
+	Name    string
+	Funcs   []*Func
+	Structs []*Struct
+	// Doc is the doc comment of the go package
+	Doc string
+	// SharedLib is the file name of the shared lib built from the lib
+	SharedLib string
+	// PyModule is the name of the main python module of the lib
+	PyModule string
+}
+
+// NewLib returns a lib with the default names of its shared lib and python module
+func NewLib(name string, funcs []*Func, structs []*Struct, doc string) *Lib {
+	return &Lib{
+		Name:      name,
+		Funcs:     funcs,
+		Structs:   structs,
+		Doc:       doc,
+		SharedLib: DefaultSharedLib(name),
+		PyModule:  name,
+	}
+}
+
+// DefaultSharedLib returns the default file name of the shared lib of a lib
+func DefaultSharedLib(lib string) string {
+	return fmt.Sprintf("_%s.so", lib)
+}
+
+// SetNames sets the file name of the shared lib, and the name of the main python module.
+// The funcs and structs of the main python module are moved to the new one.
+func (l *Lib) SetNames(sharedLib, pyModule string) {
+	for _, f := range l.Funcs {
+		f.SharedLib = sharedLib
+		if f.Module == l.PyModule {
+			f.Module = pyModule
+		}
+	}
+	for _, s := range l.Structs {
+		if s.Module == l.PyModule {
+			s.Module = pyModule
+		}
+	}
+	l.SharedLib = sharedLib
+	l.PyModule = pyModule
+}
+
+// SetStatic sets whether the lib is a static archive linked in the host process of python,
+// whose funcs are then loaded from the process instead of the shared lib
+func (l *Lib) SetStatic(static bool) {
+	for _, f := range l.Funcs {
+		f.Static = static
+	}
+}
+
+// SetRaisesErrors sets whether the python funcs raise the returned errors, as with the extension module backends
+func (l *Lib) SetRaisesErrors(raises bool) {
+	for _, f := range l.Funcs {
+		f.RaisesErrors = raises
+	}
+}
+
+func (l *Lib) String() string {
+	return fmt.Sprintf("%s: funcs: %v, structs: %v", l.Name, l.Funcs, l.Structs)
+}
+
+// PyDoc returns the docstring of the python module
+func (l *Lib) PyDoc() string {
+	return pyDocstring(l.Doc, "")
+}
+
+// PyAll returns the public names of the python module
+func (l *Lib) PyAll() []string {
+	names := []string{}
+	for _, s := range l.Structs {
+		names = append(names, s.PyName)
+	}
+	for _, f := range l.Funcs {
+		names = append(names, f.PyName)
+	}
+	return names
+}
+
+// Modules returns the names of the python modules of the lib
+func (l *Lib) Modules() []string {
+	modules := []string{l.PyModule}
+	seen := map[string]bool{l.PyModule: true}
+
+	add := func(module string) {
+		if !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+	for _, f := range l.Funcs {
+		add(f.Module)
+	}
+	for _, s := range l.Structs {
+		add(s.Module)
+	}
+	return modules
+}
+
+// Module returns the subset of the lib generated in the given python module
+func (l *Lib) Module(module string) *Lib {
+	res := &Lib{
+		Name:      l.Name,
+		Funcs:     []*Func{},
+		Structs:   []*Struct{},
+		SharedLib: l.SharedLib,
+		PyModule:  l.PyModule,
+	}
+	// the package doc only documents the main module of the lib
+	if module == l.PyModule {
+		res.Doc = l.Doc
+	}
+	for _, f := range l.Funcs {
+		if f.Module == module {
+			res.Funcs = append(res.Funcs, f)
+		}
+	}
+	for _, s := range l.Structs {
+		if s.Module == module {
+			res.Structs = append(res.Structs, s)
+		}
+	}
+	return res
+}
+
+// GoImports returns the import specs of the packages the generated go code of the lib
+// refers to, besides the lib package itself.
+func (l *Lib) GoImports() ([]string, error) {
+	// the names already used by the generated go code
+	paths := map[string]string{l.Name: "", "C": "", "fmt": "", "unsafe": ""}
+	for _, f := range l.Funcs {
+		for path, name := range f.Imports {
+			if prev, ok := paths[name]; ok && prev != path {
+				return nil, fmt.Errorf("func %s refers to package %s, whose name %s is already used in the generated code of lib %s", f.Name, path, name, l.Name)
+			}
+			paths[name] = path
+		}
+	}
+
+	specs := []string{}
+	for name, path := range paths {
+		if path != "" {
+			specs = append(specs, fmt.Sprintf("%s %q", name, path))
+		}
+	}
+	sort.Strings(specs)
+	return specs, nil
+}
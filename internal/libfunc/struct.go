@@ -0,0 +1,148 @@
+package libfunc
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	iast "github.com/yanndegat/pygo/internal/ast"
+)
+
+type Struct struct {
+	Lib    string
+	Name   string
+	Fields []Field
+	// PyName is the name of the class in the python module
+	PyName string
+	// Module is the python module the class is generated in
+	Module string
+	// Doc is the doc comment of the go struct
+	Doc string
+	// Pos is the position of the go struct in the sources
+	Pos token.Position
+}
+
+func (s Struct) IsSupported() bool {
+	return s.SupportError() == nil
+}
+
+// SupportError returns the reason why s is not supported, if it's not.
+func (s Struct) SupportError() error {
+	for _, f := range s.Fields {
+		if err := f.SupportError(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Struct) String() string {
+	return fmt.Sprintf("%s.%s: %v", s.Lib, s.Name, s.Fields)
+}
+
+// PyDoc returns the docstring of the python class
+func (s *Struct) PyDoc() string {
+	return pyDocstring(s.Doc, "    ")
+}
+
+// PyInitArgs returns the args of the python class constructor
+func (s *Struct) PyInitArgs() string {
+	args := "self"
+	for _, f := range s.Fields {
+		args = fmt.Sprintf("%s, %s=None", args, f.PyName)
+	}
+	return args
+}
+
+type Field struct {
+	Name     string
+	PyName   string
+	Type     Type
+	ReadOnly bool
+	// SourceType is the go type of the field in the sources, e.g. model.Date
+	SourceType string
+
+	// unsupported is the reason why the field can't be exchanged with python
+	unsupported error
+}
+
+// SupportError returns the reason why f can't be exchanged with python, if it can't.
+func (f Field) SupportError() error {
+	if f.unsupported != nil {
+		return fmt.Errorf("field %s is not supported: %v", f.Name, f.unsupported)
+	}
+	if !supportedType(f.Type) {
+		return fmt.Errorf("field %s of type %s is not supported", f.Name, f.Type)
+	}
+	return nil
+}
+
+func (f Field) String() string {
+	return fmt.Sprintf("%s(%s):%s", f.Name, f.PyName, f.Type)
+}
+
+func ConvertFromAstS(lib string, astS *iast.AstStruct, converters Converters) (*Struct, error) {
+	if astS == nil {
+		return nil, nil
+	}
+
+	s := &Struct{
+		Lib:    lib,
+		Name:   astS.Name,
+		Fields: []Field{},
+		PyName: astS.Name,
+		Module: lib,
+		Doc:    astS.Doc,
+		Pos:    astS.Pos,
+	}
+
+	if astS.Export != nil {
+		if name, ok := astS.Export.Options[iast.OptionName]; ok {
+			s.PyName = name
+		}
+		if module, ok := astS.Export.Options[iast.OptionModule]; ok {
+			s.Module = module
+		}
+	}
+
+	// python classes only hold the values of the fields, the go types are only used for display
+	qualifier := func(p *types.Package) string { return p.Name() }
+	for _, field := range astS.Fields {
+		if field.Omit {
+			continue
+		}
+
+		f := Field{
+			Name:     field.Name,
+			PyName:   iast.PyIdentifier(field.PyName),
+			ReadOnly: field.ReadOnly,
+		}
+
+		// the types resolved by the type checker are preferred to the ast ones
+		if field.Resolved != nil {
+			f.Type, f.unsupported = fieldType(field.Resolved, qualifier, converters)
+			f.SourceType = types.TypeString(field.Resolved, qualifier)
+		} else {
+			t, err := astTypeToType(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			f.Type = *t
+			f.SourceType = string(*t)
+		}
+
+		s.Fields = append(s.Fields, f)
+	}
+
+	return s, nil
+}
+
+// fieldType returns the type of a field in python.
+// As python classes only hold values, the fields of a type with a converter have the type of the converter.
+func fieldType(t types.Type, qualifier types.Qualifier, converters Converters) (Type, error) {
+	if c, _ := converters.lookup(t); c != nil {
+		return c.Type, nil
+	}
+	res, _, err := fromGoType(t, qualifier)
+	return res, err
+}
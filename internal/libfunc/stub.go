@@ -0,0 +1,101 @@
+package libfunc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PyHint returns the python type hint of the values of type t
+func (t Type) PyHint() string {
+	if t.IsArray() {
+		return fmt.Sprintf("list[%s]", Type(arrayTypeRe.ReplaceAllString(string(t), "$2")).PyHint())
+	}
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeByte, TypeInt, TypeInt32, TypeInt64:
+		return "int"
+	case TypeString, TypeError:
+		return "str"
+	case TypeVoid:
+		return "None"
+	}
+	return "Any"
+}
+
+// PyHint returns the python type hint of the arg. Byte slices are passed as bytes,
+// which all the backends accept as a sequence of ints.
+func (a Arg) PyHint() string {
+	if a.Type.IsArray() && a.Type.T() == TypeByte {
+		return "bytes"
+	}
+	return a.Type.PyHint()
+}
+
+// PyStubSig returns the params of the python func in its stub, with their type hints and default values
+func (f *Func) PyStubSig() string {
+	sig := make([]string, len(f.Args))
+	for i, arg := range f.Args {
+		sig[i] = fmt.Sprintf("%s: %s", arg.PyName(), arg.PyHint())
+		if arg.Default != "" {
+			sig[i] = fmt.Sprintf("%s = %s", sig[i], arg.Default)
+		}
+	}
+	return strings.Join(sig, ", ")
+}
+
+// PyResultHint returns the python type hint of the result of the python func.
+// A returned error is raised by the extension module backends, and returned as the bytes of its message,
+// or None, by the gofunc decorator. A raised error isn't hinted as NoReturn, which would mean that the func
+// never returns, while it returns None when it succeeds.
+func (f *Func) PyResultHint() string {
+	if f.Result == TypeError {
+		if f.RaisesErrors {
+			return "None"
+		}
+		return "Optional[bytes]"
+	}
+	return f.Result.PyHint()
+}
+
+// PyHint returns the python type hint of the field, which is None until it's set
+func (f Field) PyHint() string {
+	return fmt.Sprintf("Optional[%s]", f.Type.PyHint())
+}
+
+// PyStubInitArgs returns the args of the python class constructor in its stub, with their type hints
+func (s *Struct) PyStubInitArgs() string {
+	args := "self"
+	for _, f := range s.Fields {
+		args = fmt.Sprintf("%s, %s: %s = None", args, f.PyName, f.PyHint())
+	}
+	return args
+}
+
+// PyTyping returns the names the stub of the python module imports from typing
+func (l *Lib) PyTyping() []string {
+	hints := []string{}
+	for _, f := range l.Funcs {
+		for _, a := range f.Args {
+			hints = append(hints, a.PyHint())
+		}
+		hints = append(hints, f.PyResultHint())
+	}
+	for _, s := range l.Structs {
+		for _, f := range s.Fields {
+			hints = append(hints, f.PyHint())
+		}
+	}
+
+	// the names are sorted
+	names := []string{}
+	for _, name := range []string{"Any", "Optional"} {
+		for _, hint := range hints {
+			if strings.Contains(hint, name) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package libfunc
+
+import (
+	"fmt"
+	"go/ast"
+	"reflect"
+	"testing"
+
+	iast "github.com/yanndegat/pygo/internal/ast"
+)
+
+func TestMain_Func_PyStub(t *testing.T) {
+	tests := []struct {
+		Func   Func
+		Sig    string
+		Result string
+	}{
+		{
+			Func{Name: "F", Result: TypeVoid},
+			"",
+			"None",
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "from", Type: TypeString}, {Name: "n", Type: TypeInt32, Default: "1"}}, Result: TypeBool},
+			"from_: str, n: int = 1",
+			"bool",
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "b", Type: "[]byte"}, {Name: "s", Type: "[]string"}}, Result: "[]byte"},
+			"b: bytes, s: list[str]",
+			"list[int]",
+		},
+		{
+			Func{Name: "F", Args: []Arg{{Name: "l", Type: "[]bool"}}, Result: TypeError},
+			"l: list[bool]",
+			"Optional[bytes]",
+		},
+		{
+			Func{Name: "F", Result: TypeError, RaisesErrors: true},
+			"",
+			"None",
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if sig := test.Func.PyStubSig(); sig != test.Sig {
+				t.Fatalf("match should be %q, was %q", test.Sig, sig)
+			}
+			if result := test.Func.PyResultHint(); result != test.Result {
+				t.Fatalf("match should be %q, was %q", test.Result, result)
+			}
+		})
+	}
+}
+
+func TestMain_Lib_PyTyping(t *testing.T) {
+	tests := []struct {
+		Lib   *Lib
+		Match []string
+	}{
+		{
+			NewLib("lib", []*Func{{Name: "F", Args: []Arg{{Name: "s", Type: TypeString}}, Result: TypeInt}}, nil, ""),
+			[]string{},
+		},
+		{
+			NewLib("lib", []*Func{{Name: "F", Result: TypeError}}, nil, ""),
+			[]string{"Optional"},
+		},
+		{
+			NewLib("lib", []*Func{{Name: "F", Result: TypeError, RaisesErrors: true}}, []*Struct{{Name: "S", Fields: []Field{{Name: "A", PyName: "a", Type: TypeInt}}}}, ""),
+			[]string{"Optional"},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if names := test.Lib.PyTyping(); !reflect.DeepEqual(names, test.Match) {
+				t.Fatalf("match should be %v, was %v", test.Match, names)
+			}
+		})
+	}
+}
+
+func TestMain_ConvertFromAstS_keywords(t *testing.T) {
+	astS := &iast.AstStruct{Name: "S", Fields: []*iast.AstField{
+		{Name: "Class", PyName: "class", Type: ast.NewIdent("string")},
+		{Name: "None", PyName: "None", Type: ast.NewIdent("int")},
+	}}
+	s, err := ConvertFromAstS("p", astS, nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// the python keywords are escaped, as the names of the params
+	if expected := "self, class_=None, None_=None"; s.PyInitArgs() != expected {
+		t.Fatalf("match should be %q, was %q", expected, s.PyInitArgs())
+	}
+	if expected := "self, class_: Optional[str] = None, None_: Optional[int] = None"; s.PyStubInitArgs() != expected {
+		t.Fatalf("match should be %q, was %q", expected, s.PyStubInitArgs())
+	}
+}
@@ -2,7 +2,9 @@ package libfunc
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"strconv"
 )
 
 var (
@@ -123,3 +125,35 @@ func supportedType(t Type) bool {
 	}
 	return false
 }
+
+// pyLiteral returns the python literal of value, for the type t
+func pyLiteral(t Type, value string) (string, error) {
+	switch t {
+	case TypeString:
+		// go escape sequences are valid in python
+		return strconv.Quote(value), nil
+	case TypeInt, TypeInt32, TypeInt64, TypeByte:
+		i, err := strconv.ParseInt(value, 0, 64)
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return "", fmt.Errorf("%s overflows %s", value, t)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%q is not a valid %s", value, t)
+		}
+		// int is checked as int64, as go ints are 64 bits on the platforms the shared libs are built for
+		if (t == TypeInt32 && (i < math.MinInt32 || i > math.MaxInt32)) || (t == TypeByte && (i < 0 || i > math.MaxUint8)) {
+			return "", fmt.Errorf("%s overflows %s", value, t)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a valid %s", value, t)
+		}
+		if b {
+			return "True", nil
+		}
+		return "False", nil
+	}
+	return "", fmt.Errorf("default values of type %s are not supported", t)
+}
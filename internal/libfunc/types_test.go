@@ -33,3 +33,34 @@ func TestMain_Type_T(t *testing.T) {
 		})
 	}
 }
+
+func TestMain_pyLiteral(t *testing.T) {
+	tests := []struct {
+		Type    Type
+		Value   string
+		Literal string
+		Err     string
+	}{
+		{TypeByte, "255", "255", ""},
+		{TypeByte, "0x10", "16", ""},
+		{TypeByte, "300", "", "300 overflows byte"},
+		{TypeByte, "-1", "", "-1 overflows byte"},
+		{TypeInt32, "-2147483648", "-2147483648", ""},
+		{TypeInt32, "2147483648", "", "2147483648 overflows int32"},
+		{TypeInt64, "9223372036854775808", "", "9223372036854775808 overflows int64"},
+		{TypeInt, "one", "", `"one" is not a valid int`},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			literal, err := pyLiteral(test.Type, test.Value)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if literal != test.Literal || errStr != test.Err {
+				t.Fatalf("match should be %q %q, was %q %q", test.Literal, test.Err, literal, errStr)
+			}
+		})
+	}
+}
@@ -108,6 +108,16 @@ func NewLogger(name string) hclog.Logger {
 	}
 }
 
+// SetLevel overrides the log level set by the environment, e.g. with a command line flag
+func SetLevel(level string) error {
+	level = strings.ToUpper(level)
+	if !isValidLogLevel(level) {
+		return fmt.Errorf("invalid log level %q, valid levels are: %v", level, ValidLevels)
+	}
+	logger.SetLevel(hclog.LevelFromString(level))
+	return nil
+}
+
 // CurrentLogLevel returns the current log level string based the environment vars
 func CurrentLogLevel() string {
 	ll, _ := globalLogLevel()
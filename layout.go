@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/yanndegat/pygo/internal/ast"
+)
+
+const (
+	// defaultOutput is the dir in which the files are generated, relative to each package
+	defaultOutput = "pygo"
+	defaultSoName = "_{{.Lib}}"
+	defaultPyName = "{{.Lib}}"
+	// defaultArchiveName is the default name of the lib with -mode=c-archive, which linkers expect
+	defaultArchiveName = "lib{{.Lib}}"
+
+	// modeCShared builds a shared lib per lib, loaded by the python modules,
+	// and modeCArchive builds a static archive per lib, linked in the host process of the python interpreter
+	modeCShared  = "c-shared"
+	modeCArchive = "c-archive"
+	// ldflagsExt is the extension of the file listing the linker flags of a static archive
+	ldflagsExt = ".ldflags"
+
+	// generatedGoModHeader marks the go.mod files generated for output dirs outside of the module
+	generatedGoModHeader = "// Code generated by pygo; DO NOT EDIT."
+	// pyTypedFile marks the output dirs as typed python packages, for their stubs,
+	// and pyTypedHeader is its content, as it's only checked for the partial marker
+	pyTypedFile   = "py.typed"
+	pyTypedHeader = "# Code generated by pygo; DO NOT EDIT."
+)
+
+var (
+	pyModuleRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	buildTagRe = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+)
+
+// layoutData is the data of the templates of the output dir, and of the generated names
+type layoutData struct {
+	// Lib is the name of the go package
+	Lib string
+	// ImportPath is the import path of the go package
+	ImportPath string
+	// Dir is the dir of the go package
+	Dir string
+	// Pkg is the dir of the go package, relative to the root of its module
+	Pkg string
+	// ModDir is the root dir of the module
+	ModDir string
+}
+
+func newLayoutData(lib, importPath, dir string, mod *ast.Mod) layoutData {
+	pkg, err := filepath.Rel(mod.Dir, dir)
+	if err != nil {
+		pkg = "."
+	}
+	return layoutData{
+		Lib:        lib,
+		ImportPath: importPath,
+		Dir:        dir,
+		Pkg:        filepath.ToSlash(pkg),
+		ModDir:     mod.Dir,
+	}
+}
+
+// outDir returns the dir in which the files of a package are generated.
+// A relative dir is relative to the dir of the package.
+func (o *options) outDir(data layoutData) (string, error) {
+	dir, err := execLayoutTemplate("-o", o.Output, data)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(data.Dir, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// names returns the file name of the shared lib, or of the static archive with -mode=c-archive,
+// and the name of the main python module of a lib
+func (o *options) names(data layoutData) (string, string, error) {
+	soName, err := execLayoutTemplate("-so-name", o.SoName, data)
+	if err != nil {
+		return "", "", err
+	}
+	if soName == "" || strings.ContainsAny(soName, `/\`) {
+		return "", "", fmt.Errorf("invalid shared lib name %q for lib %s, it must be a file name", soName, data.Lib)
+	}
+
+	pyName, err := execLayoutTemplate("-py-name", o.PyName, data)
+	if err != nil {
+		return "", "", err
+	}
+	if !pyModuleRe.MatchString(pyName) {
+		return "", "", fmt.Errorf("invalid python module name %q for lib %s, it must be a python identifier", pyName, data.Lib)
+	}
+	if o.Mode == modeCArchive {
+		return soName + ".a", pyName, nil
+	}
+	// the extension module is named after the shared lib
+	if hasExt(o.Backend) && !pyModuleRe.MatchString(extModule(soName, o.Backend)) {
+		return "", "", fmt.Errorf("invalid shared lib name %q for lib %s, it must be a python identifier with -backend=%s", soName, data.Lib, o.Backend)
+	}
+	return soName + ".so", pyName, nil
+}
+
+func execLayoutTemplate(flag, text string, data layoutData) (string, error) {
+	tpl, err := template.New(flag).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't parse %s template: %v", flag, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Couldn't execute %s template: %v", flag, err)
+	}
+	return buf.String(), nil
+}
+
+// outsideModule returns true if dir isn't part of the module of the package
+func outsideModule(dir string, mod *ast.Mod) bool {
+	rel, err := filepath.Rel(mod.Dir, dir)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// renderGoMod renders the go.mod and go.sum of an output dir outside of the module.
+// The generated module requires the module of the package, replaced by its local dir,
+// with the same requirements, so that it's built with the same dependencies.
+func renderGoMod(dir, importPath string, mod *ast.Mod) ([]*genFile, error) {
+	data, err := ioutil.ReadFile(mod.GoMod)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read %s: %v", mod.GoMod, err)
+	}
+	mainFile, err := modfile.Parse(mod.GoMod, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't parse %s: %v", mod.GoMod, err)
+	}
+
+	modDir, err := filepath.Rel(dir, mod.Dir)
+	if err != nil {
+		modDir = mod.Dir
+	}
+
+	f := &modfile.File{Syntax: &modfile.FileSyntax{}}
+	f.AddComment(generatedGoModHeader)
+	if err := f.AddModuleStmt(fmt.Sprintf("%s/pygo", importPath)); err != nil {
+		return nil, err
+	}
+	if mainFile.Go != nil {
+		if err := f.AddGoStmt(mainFile.Go.Version); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.AddRequire(mod.Main, "v0.0.0"); err != nil {
+		return nil, err
+	}
+	for _, r := range mainFile.Require {
+		f.AddNewRequire(r.Mod.Path, r.Mod.Version, r.Indirect)
+	}
+	if err := f.AddReplace(mod.Main, "", localModPath(modDir), ""); err != nil {
+		return nil, err
+	}
+	for _, r := range mainFile.Replace {
+		newPath := r.New.Path
+		// local replacements are relative to the dir of the go.mod
+		if modfile.IsDirectoryPath(newPath) && !filepath.IsAbs(newPath) {
+			newPath = localModPath(filepath.Join(modDir, newPath))
+		}
+		if err := f.AddReplace(r.Old.Path, r.Old.Version, newPath, r.New.Version); err != nil {
+			return nil, err
+		}
+	}
+	f.SortBlocks()
+	f.Cleanup()
+
+	out, err := f.Format()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't format the go.mod of %s: %v", dir, err)
+	}
+	files := []*genFile{{Path: filepath.Join(dir, "go.mod"), Content: out}}
+
+	sum, err := ioutil.ReadFile(filepath.Join(mod.Dir, "go.sum"))
+	if os.IsNotExist(err) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(files, &genFile{Path: filepath.Join(dir, "go.sum"), Content: sum}), nil
+}
+
+// localModPath returns a path which go recognizes as a local dir in a replace directive
+func localModPath(dir string) string {
+	dir = filepath.ToSlash(dir)
+	if filepath.IsAbs(dir) || strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return dir
+	}
+	return "./" + dir
+}
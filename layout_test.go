@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/ast"
+)
+
+func TestMain_options_layout(t *testing.T) {
+	mod := &ast.Mod{Main: "example.com/m", Path: "/pkg/lib", Dir: "/src/m", GoMod: "/src/m/go.mod"}
+	data := newLayoutData("lib", "example.com/m/pkg/lib", "/src/m/pkg/lib", mod)
+
+	tests := []struct {
+		Options   options
+		OutDir    string
+		SharedLib string
+		PyModule  string
+		Outside   bool
+		Err       bool
+	}{
+		{
+			options{Output: defaultOutput, SoName: defaultSoName, PyName: defaultPyName},
+			"/src/m/pkg/lib/pygo",
+			"_lib.so",
+			"lib",
+			false,
+			false,
+		},
+		{
+			options{Output: "{{.ModDir}}/../build/{{.Pkg}}", SoName: "lib{{.Lib}}", PyName: "{{.Lib}}_api"},
+			"/src/build/pkg/lib",
+			"liblib.so",
+			"lib_api",
+			true,
+			false,
+		},
+		{
+			options{Output: "../gen", SoName: defaultSoName, PyName: defaultPyName},
+			"/src/m/pkg/gen",
+			"_lib.so",
+			"lib",
+			false,
+			false,
+		},
+		{
+			options{Output: defaultOutput, SoName: defaultArchiveName, PyName: defaultPyName, Mode: modeCArchive},
+			"/src/m/pkg/lib/pygo",
+			"liblib.a",
+			"lib",
+			false,
+			false,
+		},
+		{
+			options{Output: defaultOutput, SoName: defaultSoName, PyName: defaultPyName, Backend: backendCPython},
+			"/src/m/pkg/lib/pygo",
+			"_lib.so",
+			"lib",
+			false,
+			false,
+		},
+		{
+			options{Output: defaultOutput, SoName: "lib-{{.Lib}}", PyName: defaultPyName, Backend: backendCPython},
+			"/src/m/pkg/lib/pygo",
+			"",
+			"",
+			false,
+			true,
+		},
+		{
+			options{Output: defaultOutput, SoName: "lib-{{.Lib}}", PyName: defaultPyName, Backend: backendCffi},
+			"/src/m/pkg/lib/pygo",
+			"",
+			"",
+			false,
+			true,
+		},
+		{
+			options{Output: defaultOutput, SoName: defaultSoName, PyName: "{{.ImportPath}}"},
+			"/src/m/pkg/lib/pygo",
+			"",
+			"",
+			false,
+			true,
+		},
+		{
+			options{Output: defaultOutput, SoName: "{{.Unknown}}", PyName: defaultPyName},
+			"/src/m/pkg/lib/pygo",
+			"",
+			"",
+			false,
+			true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			outDir, err := test.Options.outDir(data)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if outDir != test.OutDir {
+				t.Fatalf("match should be %v, was %v", test.OutDir, outDir)
+			}
+			if outsideModule(outDir, mod) != test.Outside {
+				t.Fatalf("match should be %v, was %v", test.Outside, !test.Outside)
+			}
+
+			sharedLib, pyModule, err := test.Options.names(data)
+			if (err != nil) != test.Err {
+				t.Fatalf("match should be %v, was %v", test.Err, err)
+			}
+			if sharedLib != test.SharedLib || pyModule != test.PyModule {
+				t.Fatalf("match should be %v %v, was %v %v", test.SharedLib, test.PyModule, sharedLib, pyModule)
+			}
+		})
+	}
+}
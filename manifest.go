@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"sort"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+// manifestVersion is the version of the format of the manifest printed by pygo inspect -json.
+// Fields may be added, but it's incremented when fields are removed or change of meaning.
+const manifestVersion = 1
+
+// manifest is the python API generated from the scanned packages, for the tools which document,
+// audit or review it. Unlike the text output of pygo inspect, its format is stable.
+type manifest struct {
+	Version  int                `json:"version"`
+	Mode     string             `json:"mode"`
+	Backend  string             `json:"backend"`
+	Packages []*manifestPackage `json:"packages"`
+}
+
+type manifestPackage struct {
+	ImportPath string `json:"import_path"`
+	// Dir and OutDir are relative to the dir pygo is run in, as the files of the positions
+	Dir    string         `json:"dir"`
+	OutDir string         `json:"out_dir"`
+	Libs   []*manifestLib `json:"libs"`
+}
+
+type manifestLib struct {
+	Name string `json:"name"`
+	Doc  string `json:"doc,omitempty"`
+	// SharedLib and Modules are empty if nothing is exported from the lib
+	SharedLib string            `json:"shared_lib,omitempty"`
+	Modules   []string          `json:"modules"`
+	Funcs     []*manifestFunc   `json:"funcs"`
+	Structs   []*manifestStruct `json:"structs"`
+}
+
+// manifestDecl are the fields shared by the funcs and the structs
+type manifestDecl struct {
+	Name   string `json:"name"`
+	PyName string `json:"py_name"`
+	Module string `json:"module"`
+	// Status is one of the status* constants, and Reason why it's not exported, if it's not
+	Status   string      `json:"status"`
+	Reason   string      `json:"reason,omitempty"`
+	Implicit bool        `json:"implicit,omitempty"`
+	Pos      manifestPos `json:"pos"`
+	Doc      string      `json:"doc,omitempty"`
+}
+
+type manifestPos struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type manifestFunc struct {
+	manifestDecl
+	HoldGil      bool             `json:"hold_gil,omitempty"`
+	RaisesErrors bool             `json:"raises_errors,omitempty"`
+	Params       []*manifestParam `json:"params"`
+	// Result is nil if the func returns nothing
+	Result *manifestType `json:"result,omitempty"`
+}
+
+// manifestType is a type in go, in the header of the shared lib, and in python.
+// The C and python types are empty if the type can't be exchanged with python.
+type manifestType struct {
+	GoType string `json:"go_type"`
+	CType  string `json:"c_type,omitempty"`
+	PyType string `json:"py_type,omitempty"`
+}
+
+type manifestParam struct {
+	Name   string `json:"name"`
+	PyName string `json:"py_name"`
+	manifestType
+	Default string `json:"default,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type manifestStruct struct {
+	manifestDecl
+	Fields []*manifestField `json:"fields"`
+}
+
+type manifestField struct {
+	Name     string `json:"name"`
+	PyName   string `json:"py_name"`
+	GoType   string `json:"go_type"`
+	PyType   string `json:"py_type,omitempty"`
+	ReadOnly bool   `json:"readonly,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// writeManifest writes the manifest of the packages as indented JSON.
+// The paths are relative to dir, so that it doesn't depend on where the module is checked out.
+func writeManifest(w io.Writer, pyPkgs []*pyPkg, o *options, dir string) error {
+	m := &manifest{Version: manifestVersion, Mode: o.Mode, Backend: o.Backend, Packages: []*manifestPackage{}}
+	for _, p := range pyPkgs {
+		m.Packages = append(m.Packages, newManifestPackage(p, dir))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(m)
+}
+
+func newManifestPackage(p *pyPkg, dir string) *manifestPackage {
+	mp := &manifestPackage{ImportPath: p.ImportPath, Dir: relPath(dir, p.Dir), OutDir: relPath(dir, p.OutDir), Libs: []*manifestLib{}}
+
+	libs := map[string]*manifestLib{}
+	lib := func(name string) *manifestLib {
+		if _, ok := libs[name]; !ok {
+			libs[name] = &manifestLib{Name: name, Modules: []string{}, Funcs: []*manifestFunc{}, Structs: []*manifestStruct{}}
+			mp.Libs = append(mp.Libs, libs[name])
+		}
+		return libs[name]
+	}
+
+	for _, pyLib := range p.Libs {
+		ml := lib(pyLib.Name)
+		ml.Doc = pyLib.Doc
+		ml.SharedLib = pyLib.SharedLib
+		ml.Modules = pyLib.Modules()
+		for _, f := range pyLib.Funcs {
+			ml.Funcs = append(ml.Funcs, newManifestFunc(f, statusExported, "", false, dir))
+		}
+		for _, s := range pyLib.Structs {
+			ml.Structs = append(ml.Structs, newManifestStruct(s, statusExported, "", dir))
+		}
+	}
+	for _, r := range p.Rejected {
+		if r.Func != nil {
+			ml := lib(r.Func.Lib)
+			ml.Funcs = append(ml.Funcs, newManifestFunc(r.Func, r.Status, r.Reason, r.Implicit, dir))
+		} else {
+			ml := lib(r.Struct.Lib)
+			ml.Structs = append(ml.Structs, newManifestStruct(r.Struct, r.Status, r.Reason, dir))
+		}
+	}
+
+	// the exported and the rejected declarations are listed in the order of the sources
+	sort.Slice(mp.Libs, func(i, j int) bool {
+		return mp.Libs[i].Name < mp.Libs[j].Name
+	})
+	for _, ml := range mp.Libs {
+		funcs := ml.Funcs
+		sort.SliceStable(funcs, func(i, j int) bool {
+			return funcs[i].Pos.less(funcs[j].Pos)
+		})
+		structs := ml.Structs
+		sort.SliceStable(structs, func(i, j int) bool {
+			return structs[i].Pos.less(structs[j].Pos)
+		})
+	}
+	return mp
+}
+
+func newManifestFunc(f *libfunc.Func, status, reason string, implicit bool, dir string) *manifestFunc {
+	mf := &manifestFunc{
+		manifestDecl: manifestDecl{Name: f.Name, PyName: f.PyName, Module: f.Module, Status: status, Reason: reason,
+			Implicit: implicit, Pos: newManifestPos(f.Pos, dir), Doc: f.Doc},
+		HoldGil:      f.HoldGil,
+		RaisesErrors: f.RaisesErrors && status == statusExported,
+		Params:       []*manifestParam{},
+	}
+	for _, a := range f.Args {
+		mpa := &manifestParam{Name: a.Name, PyName: a.PyName(), manifestType: manifestType{GoType: a.SourceType}, Default: a.Default}
+		if err := a.SupportError(); err != nil {
+			mpa.Reason = err.Error()
+		} else {
+			mpa.CType = paramCType(a)
+			mpa.PyType = a.PyHint()
+		}
+		mf.Params = append(mf.Params, mpa)
+	}
+	if !f.IsVoid() {
+		mf.Result = &manifestType{GoType: f.ResultSourceType}
+		// the types of the results of the rejected funcs may not be supported
+		if status == statusExported {
+			mf.Result.CType = f.CResultType()
+			mf.Result.PyType = f.PyResultHint()
+		}
+	}
+	return mf
+}
+
+// paramCType returns the C type of a param in the header of the shared lib. cgo declares the error params
+// of the wrappers as GoInterface, which isn't one of the types the backends convert, so only the manifest knows it.
+func paramCType(a libfunc.Arg) string {
+	if a.Type == libfunc.TypeError {
+		return "GoInterface"
+	}
+	return a.CType()
+}
+
+func newManifestStruct(s *libfunc.Struct, status, reason string, dir string) *manifestStruct {
+	ms := &manifestStruct{
+		manifestDecl: manifestDecl{Name: s.Name, PyName: s.PyName, Module: s.Module, Status: status, Reason: reason,
+			Pos: newManifestPos(s.Pos, dir), Doc: s.Doc},
+		Fields: []*manifestField{},
+	}
+	for _, f := range s.Fields {
+		mf := &manifestField{Name: f.Name, PyName: f.PyName, GoType: f.SourceType, ReadOnly: f.ReadOnly}
+		if err := f.SupportError(); err != nil {
+			mf.Reason = err.Error()
+		} else {
+			mf.PyType = f.PyHint()
+		}
+		ms.Fields = append(ms.Fields, mf)
+	}
+	return ms
+}
+
+func newManifestPos(pos token.Position, dir string) manifestPos {
+	return manifestPos{File: relPath(dir, pos.Filename), Line: pos.Line, Column: pos.Column}
+}
+
+func (p manifestPos) less(q manifestPos) bool {
+	if p.File != q.File {
+		return p.File < q.File
+	}
+	if p.Line != q.Line {
+		return p.Line < q.Line
+	}
+	return p.Column < q.Column
+}
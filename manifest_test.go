@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func TestMain_writeManifest(t *testing.T) {
+	greet := &libfunc.Func{Lib: "mylib", Name: "Greet", PyName: "greet", Module: "mylib", Doc: "Greet greets",
+		Args:   []libfunc.Arg{{Name: "name", Type: libfunc.TypeString, SourceType: "model.Name"}, {Name: "cause", Type: libfunc.TypeError, SourceType: "error"}, {Name: "times", Type: libfunc.TypeInt, SourceType: "int", Default: "1"}},
+		Result: libfunc.TypeError, ResultSourceType: "error", RaisesErrors: true,
+		Pos: token.Position{Filename: "/src/m/mylib/b.go", Line: 3, Column: 6}}
+	pyLib := libfunc.NewLib("mylib", []*libfunc.Func{greet}, nil, "mylib greets")
+	rejected := &libfunc.Func{Lib: "mylib", Name: "Load", PyName: "Load", Module: "mylib", Result: libfunc.TypeVoid,
+		Args: []libfunc.Arg{{Name: "s", Type: "model.User", SourceType: "model.User"}},
+		Pos:  token.Position{Filename: "/src/m/mylib/a.go", Line: 10, Column: 6}}
+	skipped := &libfunc.Func{Lib: "other", Name: "Debug", PyName: "Debug", Module: "other", Result: libfunc.TypeVoid,
+		Pos: token.Position{Filename: "/src/m/mylib/c.go", Line: 1, Column: 6}}
+	p := &pyPkg{Dir: "/src/m/mylib", ImportPath: "m/mylib", OutDir: "/src/m/mylib/pygo", Libs: []*libfunc.Lib{pyLib},
+		Rejected: []*rejectedDecl{
+			{Func: rejected, Status: statusUnsupported, Reason: "param s is not supported", Implicit: true},
+			{Func: skipped, Status: statusSkipped, Reason: "skipped by pygo.json"},
+		}}
+
+	var buf bytes.Buffer
+	o := &options{Mode: modeCShared, Backend: backendCPython}
+	if err := writeManifest(&buf, []*pyPkg{p}, o, "/src/m"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(buf.Bytes(), m); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := &manifest{Version: manifestVersion, Mode: modeCShared, Backend: backendCPython, Packages: []*manifestPackage{{
+		ImportPath: "m/mylib",
+		Dir:        "mylib",
+		OutDir:     "mylib/pygo",
+		Libs: []*manifestLib{
+			{
+				Name:      "mylib",
+				Doc:       "mylib greets",
+				SharedLib: "_mylib.so",
+				Modules:   []string{"mylib"},
+				// the funcs are in the order of the sources
+				Funcs: []*manifestFunc{
+					{
+						manifestDecl: manifestDecl{Name: "Load", PyName: "Load", Module: "mylib", Status: statusUnsupported, Reason: "param s is not supported",
+							Implicit: true, Pos: manifestPos{File: "mylib/a.go", Line: 10, Column: 6}},
+						Params: []*manifestParam{{Name: "s", PyName: "s", manifestType: manifestType{GoType: "model.User"}, Reason: "param s of type model.User is not supported"}},
+					},
+					{
+						manifestDecl: manifestDecl{Name: "Greet", PyName: "greet", Module: "mylib", Status: statusExported,
+							Pos: manifestPos{File: "mylib/b.go", Line: 3, Column: 6}, Doc: "Greet greets"},
+						RaisesErrors: true,
+						Params: []*manifestParam{
+							{Name: "name", PyName: "name", manifestType: manifestType{GoType: "model.Name", CType: "GoString", PyType: "str"}},
+							{Name: "cause", PyName: "cause", manifestType: manifestType{GoType: "error", CType: "GoInterface", PyType: "str"}},
+							{Name: "times", PyName: "times", manifestType: manifestType{GoType: "int", CType: "GoInt", PyType: "int"}, Default: "1"},
+						},
+						Result: &manifestType{GoType: "error", CType: "char*", PyType: "None"},
+					},
+				},
+				Structs: []*manifestStruct{},
+			},
+			// the libs with nothing exported are listed too
+			{
+				Name:    "other",
+				Modules: []string{},
+				Funcs: []*manifestFunc{{
+					manifestDecl: manifestDecl{Name: "Debug", PyName: "Debug", Module: "other", Status: statusSkipped, Reason: "skipped by pygo.json",
+						Pos: manifestPos{File: "mylib/c.go", Line: 1, Column: 6}},
+					Params: []*manifestParam{},
+				}},
+				Structs: []*manifestStruct{},
+			},
+		},
+	}}}
+	if !reflect.DeepEqual(m, expected) {
+		actual, _ := json.Marshal(m)
+		wanted, _ := json.Marshal(expected)
+		t.Fatalf("match should be %s, was %s", wanted, actual)
+	}
+
+	// the manifest is stable
+	var again bytes.Buffer
+	if err := writeManifest(&again, []*pyPkg{p}, o, "/src/m"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if again.String() != buf.String() {
+		t.Fatalf("match should be %s, was %s", buf.String(), again.String())
+	}
+}
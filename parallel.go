@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// jobs returns the number of jobs run in parallel, which defaults to the number of CPUs
+func (o *options) jobs() int {
+	if o.Jobs < 1 {
+		return runtime.NumCPU()
+	}
+	return o.Jobs
+}
+
+// jobLog collects the logs of a job, which are printed once it's done,
+// so that the logs of the jobs run in parallel aren't interleaved.
+type jobLog struct {
+	buf bytes.Buffer
+}
+
+func (l *jobLog) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(&l.buf, format+"\n", v...)
+}
+
+// Writer returns a writer for the output of the commands run by the job
+func (l *jobLog) Writer() io.Writer {
+	return &l.buf
+}
+
+// flush prints the logs of the job, line by line, so that their levels are still inferred
+func (l *jobLog) flush() {
+	scanner := bufio.NewScanner(&l.buf)
+	for scanner.Scan() {
+		log.Print(scanner.Text())
+	}
+	l.buf.Reset()
+}
+
+// jobErrors are the errors of the jobs which failed
+type jobErrors []error
+
+func (e jobErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d jobs failed:\n  %s", len(e), strings.Join(msgs, "\n  "))
+}
+
+// runJobs runs the jobs on at most n workers. Once a job failed, the pending jobs are skipped,
+// and the errors of the failed jobs are returned once the running ones are done.
+func runJobs(n int, jobs []func(l *jobLog) error) error {
+	if n < 1 {
+		n = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		errs   = make([]error, len(jobs))
+		failed bool
+		wg     sync.WaitGroup
+	)
+
+	queue := make(chan int)
+	for w := 0; w < n && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				// a job may be queued before another one fails
+				mu.Lock()
+				stop := failed
+				mu.Unlock()
+				if stop {
+					continue
+				}
+
+				l := &jobLog{}
+				err := jobs[i](l)
+
+				mu.Lock()
+				l.flush()
+				if err != nil {
+					errs[i] = err
+					failed = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range jobs {
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			break
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	// the errors are returned in the order of the jobs
+	failures := jobErrors{}
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	switch len(failures) {
+	case 0:
+		return nil
+	case 1:
+		return failures[0]
+	}
+	return failures
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain_runJobs(t *testing.T) {
+	tests := []struct {
+		Workers int
+		Jobs    int
+		// Failing are the jobs which fail
+		Failing map[int]bool
+		Err     string
+		// MaxRun is the max number of jobs which may have run
+		MaxRun int
+	}{
+		{4, 10, nil, "", 10},
+		{0, 3, nil, "", 3},
+		{1, 5, map[int]bool{1: true}, "job 1 failed", 2},
+		{2, 2, map[int]bool{0: true, 1: true}, "2 jobs failed:\n  job 0 failed\n  job 1 failed", 2},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var buf bytes.Buffer
+			prevOutput, prevFlags := log.Writer(), log.Flags()
+			log.SetOutput(&buf)
+			log.SetFlags(0)
+			defer func() {
+				log.SetOutput(prevOutput)
+				log.SetFlags(prevFlags)
+			}()
+
+			var mu sync.Mutex
+			running, maxRunning, run := 0, 0, 0
+			jobs := []func(l *jobLog) error{}
+			for j := 0; j < test.Jobs; j++ {
+				j := j
+				jobs = append(jobs, func(l *jobLog) error {
+					mu.Lock()
+					running++
+					run++
+					if running > maxRunning {
+						maxRunning = running
+					}
+					mu.Unlock()
+
+					l.Printf("[INFO] job %d starts", j)
+					time.Sleep(time.Millisecond)
+					l.Printf("[INFO] job %d ends", j)
+
+					mu.Lock()
+					running--
+					mu.Unlock()
+					if test.Failing[j] {
+						return fmt.Errorf("job %d failed", j)
+					}
+					return nil
+				})
+			}
+
+			err := runJobs(test.Workers, jobs)
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if errStr != test.Err {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+
+			workers := test.Workers
+			if workers < 1 {
+				workers = 1
+			}
+			if maxRunning > workers {
+				t.Fatalf("match should be <= %v, was %v", workers, maxRunning)
+			}
+			if run > test.MaxRun {
+				t.Fatalf("match should be <= %v, was %v", test.MaxRun, run)
+			}
+
+			// the logs of each job are grouped
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			for l := 0; l+1 < len(lines); l += 2 {
+				var start, end int
+				fmt.Sscanf(lines[l], "[INFO] job %d starts", &start)
+				fmt.Sscanf(lines[l+1], "[INFO] job %d ends", &end)
+				if start != end {
+					t.Fatalf("match should be %v, was %v", start, end)
+				}
+			}
+		})
+	}
+}
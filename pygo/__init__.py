@@ -1,3 +1,5 @@
 from pygo.gofunc import gofunc
 from pygo.gofunc import GoString
 from pygo.gofunc import _map_ctype
+from pygo.ext import load_ext
+from pygo import gocffi
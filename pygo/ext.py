@@ -0,0 +1,53 @@
+import importlib.machinery
+import importlib.util
+import os
+
+from threading import RLock
+
+_EXTS = {}
+_EXTS_LOCK = RLock()
+
+
+def load_ext(file, name):
+    """
+    load_ext loads an extension module built by pygo with
+    -backend=cpython or cffi, from the dir of the python module
+    importing it.
+
+    Example:
+
+    ```
+    //mylib.py
+    from pygo import load_ext
+    _ext = load_ext(__file__, "_mylib_ext")
+    myGoFunc = _ext.myGoFunc
+    ```
+
+    :param file: The path of the python module importing the
+                 extension module, i.e. its `__file__`.
+    :type file: string
+
+    :param name: The name of the extension module, without the
+                 suffix of the python interpreter, e.g.
+                 `.cpython-311-x86_64-linux-gnu.so`.
+    :type name: string
+    """
+    libPath = os.path.dirname(os.path.abspath(file))
+    with _EXTS_LOCK:
+        if (libPath, name) not in _EXTS:
+            for suffix in importlib.machinery.EXTENSION_SUFFIXES:
+                path = os.path.join(libPath, name + suffix)
+                if os.path.exists(path):
+                    break
+            else:
+                raise ImportError(
+                    f"extension module {name} not found in {libPath},"
+                    " it's built by pygo -backend=cpython or cffi",
+                    name=name)
+
+            spec = importlib.util.spec_from_file_location(name, path)
+            ext = importlib.util.module_from_spec(spec)
+            spec.loader.exec_module(ext)
+            _EXTS[(libPath, name)] = ext
+
+        return _EXTS[(libPath, name)]
@@ -0,0 +1,106 @@
+"""
+gocffi converts the args and the results of the go funcs called by the
+python modules generated by pygo with -backend=cffi.
+"""
+
+
+class Args(object):
+    """
+    Args converts the args of a call of a go func to their C values.
+    It keeps their C buffers alive until it's released, after the call.
+
+    Example:
+
+    ```
+    //mylib.py
+    def join(words, sep):
+        _args = gocffi.Args(_ffi)
+        return gocffi.from_string(
+            _ffi, _lib, _lib.Join(_args.strings(words), _args.string(sep)))
+    ```
+
+    :param ffi: The FFI of the extension module of the lib.
+    :type ffi: cffi.FFI
+    """
+
+    def __init__(self, ffi):
+        self._ffi = ffi
+        self._bufs = []
+
+    def _new(self, ctype, init=None):
+        buf = self._ffi.new(ctype, init)
+        self._bufs.append(buf)
+        return buf
+
+    def string(self, value):
+        """
+        string returns the GoString of a str, encoded in utf-8.
+        """
+        if not isinstance(value, str):
+            raise TypeError(
+                f"expected str, got {type(value).__name__}")
+        data = value.encode("utf-8")
+        s = self._new("GoString *")
+        s.p = self._new("char[]", data)
+        s.n = len(data)
+        return s[0]
+
+    def slice(self, ctype, values):
+        """
+        slice returns the GoSlice of a sequence, whose elems are
+        converted to the C type ctype, e.g. GoInt.
+        """
+        values = list(values)
+        return self._slice(self._new(ctype + "[]", values), len(values))
+
+    def strings(self, values):
+        """
+        strings returns the GoSlice of a sequence of str.
+        """
+        values = list(values)
+        data = self._new("GoString[]", len(values))
+        for i, value in enumerate(values):
+            data[i] = self.string(value)
+        return self._slice(data, len(values))
+
+    def _slice(self, data, n):
+        s = self._new("GoSlice *")
+        s.data = data
+        s.len = n
+        s.cap = n
+        return s[0]
+
+
+def from_string(ffi, lib, res):
+    """
+    from_string returns the str of a C string returned by go, and frees it.
+    """
+    try:
+        return ffi.string(res).decode("utf-8")
+    finally:
+        lib.freeMem(res)
+
+
+def from_error(ffi, lib, res):
+    """
+    from_error raises a RuntimeError with the message of a C string
+    returned by go, and frees it. It returns None if there's no error.
+    """
+    if res == ffi.NULL:
+        return None
+    raise RuntimeError(from_string(ffi, lib, res))
+
+
+def from_slice(ffi, lib, res, ctype, conv=None):
+    """
+    from_slice returns the list of a C slice returned by go, whose elems
+    of the C type ctype are converted by conv if it's set, and frees it.
+    """
+    try:
+        values = ffi.unpack(ffi.cast(ctype + " *", res.data), res.len)
+    finally:
+        lib.freeMem(res.data)
+        lib.freeMem(res)
+    if conv is not None:
+        values = [conv(v) for v in values]
+    return values
@@ -1,3 +1,4 @@
+import functools
 import inspect
 import re
 import os
@@ -57,6 +58,8 @@ class gofunc(object):
     The signature of the python method is used to infer golang func argument
     types and return type. You can use _IX to index the arg names if types have
     to be repeated.
+    If the `sig` param is set, the names of the args of the python method are
+    free, and can be used as keyword args.
 
     Examples:
 
@@ -104,6 +107,18 @@ class gofunc(object):
                   Will override the name of the python function.
 
     :type fname: string
+
+    :param gil: "release" (default) releases the GIL during the call
+                of the go func, "hold" keeps it.
+
+    :type gil: string
+
+    :param static: True if the golang lib is a static archive linked
+                   in the host process of python, e.g. an embedded
+                   interpreter. The func is then loaded from the
+                   process, and lib only names the archive.
+
+    :type static: bool
     """
 
     def __init__(self,
@@ -111,7 +126,9 @@ class gofunc(object):
                  libPath=None,
                  sig=None,
                  fname=None,
-                 freeMemFunc="freeMem"):
+                 freeMemFunc="freeMem",
+                 gil="release",
+                 static=False):
         if lib is None or not isinstance(lib, str):
             raise Exception("lib is mandatory and has to be a string"
                             " representing the file path of a go lib.")
@@ -126,12 +143,16 @@ class gofunc(object):
         if fname is not None and not isinstance(fname, str):
             raise Exception("fname has to be a string representing a valid"
                             " function name of a go lib func.")
+        if gil not in ("release", "hold"):
+            raise Exception("gil has to be either \"release\" or \"hold\".")
 
         self.lib = lib
         self.libPath = libPath
         self.fname = fname
         self.sig = sig
         self.freeMemFunc = freeMemFunc
+        self.gil = gil
+        self.static = static
 
         return
 
@@ -140,7 +161,7 @@ class gofunc(object):
             libPath = self.libPath
             if libPath is None:
                 libPath = os.path.dirname(f.__code__.co_filename)
-            self.lib = _load_lib(libPath, self.lib)
+            self.lib = _load_lib(libPath, self.lib, self.gil, self.static)
         except Exception as e:
             raise e
 
@@ -177,7 +198,18 @@ class gofunc(object):
         self.func.restype = _map_ret_ctype(self.sig[-1])
         self.conv = [_map_conv(t) for t in self.sig[:-1]]
 
-        def wrapped_f(*args):
+        # keyword args and default values are resolved
+        # with the signature of f
+        signature = inspect.signature(f)
+        nargs = len(self.conv)
+
+        # keeps the name and the docstring of f
+        @functools.wraps(f)
+        def wrapped_f(*args, **kwargs):
+            if kwargs or len(args) < nargs:
+                bound = signature.bind(*args, **kwargs)
+                bound.apply_defaults()
+                args = bound.args
             conv_args = [self.conv[i](arg) for i, arg in enumerate(args)]
             return self._handle_ret_value(self.func(*conv_args), self.sig[-1])
 
@@ -316,9 +348,14 @@ def _map_ctype(t):
         raise Exception(f"unkwon type {t}.")
 
 
-def _load_lib(libPath, lib):
+def _load_lib(libPath, lib, gil="release", static=False):
+    # a PyDLL doesn't release the GIL during the calls
+    loader = ctypes.pydll if gil == "hold" else ctypes.cdll
+    # the libs of different packages may have the same name, so they're cached by path
+    # the symbols of a static archive are the ones of the process
+    path = None if static else os.path.abspath(os.path.join(libPath, lib))
     with _LIBS_LOCK:
-        if lib not in _LIBS:
-            _LIBS[lib] = ctypes.cdll.LoadLibrary(os.path.join(".", libPath, lib))
+        if (path, gil) not in _LIBS:
+            _LIBS[(path, gil)] = loader.LoadLibrary(path)
 
-        return _LIBS[lib]
+        return _LIBS[(path, gil)]
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+// stampExt is the extension of the stamp files, written next to the shared libs
+const stampExt = ".stamp"
+
+// buildEnv are the environment variables which change the build of the shared libs
+var buildEnv = []string{"CC", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_LDFLAGS", "GOARCH", "GOFLAGS", "GOOS"}
+
+// stampPath returns the path of the stamp file of the shared lib of a lib
+func stampPath(dir string, pyLib *libfunc.Lib) string {
+	return filepath.Join(dir, pyLib.SharedLib+stampExt)
+}
+
+// buildStamp hashes the inputs of the build of the shared lib of a lib: the generated go files,
+// the compiled package and its dependencies, the build tags and flags, and the environment,
+// and with the cpython backend, the generated C file and the python interpreter.
+// The shared lib doesn't need to be built again as long as its stamp doesn't change.
+func buildStamp(p *pyPkg, pyLib *libfunc.Lib, b *buildOptions) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "pygo %s\n", pygoVersion())
+	fmt.Fprintf(h, "lib %s\n", pyLib.SharedLib)
+	fmt.Fprintf(h, "go %s\n", b.Go)
+	for _, arg := range b.buildArgs() {
+		fmt.Fprintf(h, "arg %s\n", arg)
+	}
+	for _, env := range buildEnv {
+		fmt.Fprintf(h, "env %s=%s\n", env, os.Getenv(env))
+	}
+	for _, env := range b.Env {
+		fmt.Fprintf(h, "env %s\n", env)
+	}
+	for _, input := range p.BuildInputs {
+		fmt.Fprintf(h, "dep %s\n", input)
+	}
+
+	// the generated go.mod and go.sum of an output dir outside of the module are optional
+	goFile := fmt.Sprintf("%s.go", pyLib.Name)
+	files := []string{goFile, "go.mod", "go.sum"}
+	required := map[string]bool{goFile: true}
+	if hasExt(b.Backend) {
+		fmt.Fprintf(h, "python %s %s %s\n", b.Python, b.python.Include, b.python.ExtSuffix)
+		fmt.Fprintf(h, "cc %s\n", b.cc())
+		source := extSource(pyLib.SharedLib, b.Backend)
+		files = append(files, source)
+		required[source] = true
+	}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(filepath.Join(p.OutDir, name))
+		if os.IsNotExist(err) && !required[name] {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %s %d\n", name, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// upToDate returns true if the shared lib of a lib, and its extension module with the cpython and cffi backends, exist,
+// and were built with the same stamp
+func upToDate(dir string, pyLib *libfunc.Lib, b *buildOptions, stamp string) bool {
+	outputs := []string{pyLib.SharedLib}
+	if hasExt(b.Backend) {
+		outputs = append(outputs, b.extFile(pyLib))
+	}
+	for _, output := range outputs {
+		if _, err := os.Stat(filepath.Join(dir, output)); err != nil {
+			return false
+		}
+	}
+	data, err := ioutil.ReadFile(stampPath(dir, pyLib))
+	return err == nil && strings.TrimSpace(string(data)) == stamp
+}
+
+// writeStamp writes the stamp of the shared lib of a lib, once it's built
+func writeStamp(dir string, pyLib *libfunc.Lib, stamp string) error {
+	path := stampPath(dir, pyLib)
+	if err := ioutil.WriteFile(path, []byte(stamp+"\n"), 0644); err != nil {
+		return fmt.Errorf("Couldn't write file %s: %v", path, err)
+	}
+	return nil
+}
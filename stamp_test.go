@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func TestMain_buildStamp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pyLib := &libfunc.Lib{Name: "lib", SharedLib: "_lib.so"}
+	p := &pyPkg{OutDir: dir, BuildInputs: []string{"m/lib /cache/aa-d"}}
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	writeFile("lib.go", "package main\n")
+
+	b := &buildOptions{}
+	stamp, err := buildStamp(p, pyLib, b)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// the shared lib is built once its stamp is written
+	if upToDate(dir, pyLib, b, stamp) {
+		t.Fatalf("match should be %v, was %v", false, true)
+	}
+	writeFile("_lib.so", "")
+	if err := writeStamp(dir, pyLib, stamp); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !upToDate(dir, pyLib, b, stamp) {
+		t.Fatalf("match should be %v, was %v", true, false)
+	}
+
+	tests := []struct {
+		Change func() *buildOptions
+	}{
+		// build tags
+		{func() *buildOptions { return &buildOptions{Tags: []string{"foo"}} }},
+		// build flags
+		{func() *buildOptions { return &buildOptions{TrimPath: true} }},
+		{func() *buildOptions { return &buildOptions{LDFlags: "-s -w"} }},
+		{func() *buildOptions { return &buildOptions{Flags: []string{"-a"}} }},
+		// go binary
+		{func() *buildOptions { return &buildOptions{Go: "/usr/local/go/bin/go"} }},
+		// build environment
+		{func() *buildOptions { return &buildOptions{Env: []string{"CGO_CFLAGS=-O3"}} }},
+		// generated go file
+		{func() *buildOptions { writeFile("lib.go", "package main\n\n// changed\n"); return &buildOptions{} }},
+		// generated go.mod
+		{func() *buildOptions { writeFile("go.mod", "module m/pygo\n"); return &buildOptions{} }},
+		// compiled dependencies
+		{func() *buildOptions { p.BuildInputs = []string{"m/lib /cache/bb-d"}; return &buildOptions{} }},
+		// generated C extension module
+		{func() *buildOptions {
+			writeFile("_lib_ext.c", "// Code generated by pygo; DO NOT EDIT.\n")
+			writeFile("_lib_ext.so", "")
+			return &buildOptions{Backend: backendCPython, Python: "python3", python: &pythonConfig{Include: "/usr/include/python3.11", ExtSuffix: ".so"}}
+		}},
+		{func() *buildOptions {
+			return &buildOptions{Backend: backendCPython, Python: "python3.12", python: &pythonConfig{Include: "/usr/include/python3.12", ExtSuffix: ".so"}}
+		}},
+		// generated cffi build script
+		{func() *buildOptions {
+			writeFile("_lib_cffi_build.py", "# Code generated by pygo; DO NOT EDIT.\n")
+			writeFile("_lib_cffi.so", "")
+			return &buildOptions{Backend: backendCffi, Python: "python3.12", python: &pythonConfig{Include: "/usr/include/python3.12", ExtSuffix: ".so"}}
+		}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			b := test.Change()
+			newStamp, err := buildStamp(p, pyLib, b)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if upToDate(dir, pyLib, b, newStamp) {
+				t.Fatalf("match should be %v, was %v", false, true)
+			}
+			stamp = newStamp
+			if err := writeStamp(dir, pyLib, stamp); err != nil {
+				t.Fatalf("%v", err)
+			}
+		})
+	}
+}
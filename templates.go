@@ -0,0 +1,878 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/yanndegat/pygo/internal/ast"
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+const (
+	// goTemplate renders the go file of a lib, with a goFileData
+	goTemplate = "lib.go.tmpl"
+	// pyTemplate renders a python module of a lib, with a pyFileData
+	pyTemplate = "lib.py.tmpl"
+	// resToSliceTemplate renders the body of a go wrapper returning a slice, with a *libfunc.Func
+	resToSliceTemplate = "res_to_slice.go.tmpl"
+	// pyStructsTemplate renders the classes of the structs of a python module, with a pyFileData
+	pyStructsTemplate = "structs.py.tmpl"
+	// extTemplate renders the C extension module of a lib with -backend=cpython, with an extFileData
+	extTemplate = "ext.c.tmpl"
+	// pyExtTemplate renders a python module of a lib with -backend=cpython, with a pyFileData
+	pyExtTemplate = "lib_ext.py.tmpl"
+	// cffiBuildTemplate renders the cffi build script of a lib with -backend=cffi, with an extFileData
+	cffiBuildTemplate = "cffi_build.py.tmpl"
+	// pyCffiTemplate renders a python module of a lib with -backend=cffi, with a pyFileData
+	pyCffiTemplate = "lib_cffi.py.tmpl"
+	// pyStubTemplate renders the stub of a python module of a lib, with a pyFileData
+	pyStubTemplate = "lib.pyi.tmpl"
+	// pyprojectTemplate and pyInitTemplate render the pyproject.toml and the __init__.py of the python package
+	// laid out by pygo package, with a packageFileData
+	pyprojectTemplate = "pyproject.toml.tmpl"
+	pyInitTemplate    = "__init__.py.tmpl"
+)
+
+// defaultTemplates are the templates of the generated files, by name.
+// The empty blocks are hooks, which the templates of -templates can define
+// instead of overriding a whole file, e.g. {{ define "go_imports" }}"log"{{ end }}.
+var defaultTemplates = map[string]string{
+	goTemplate: `// Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+// This file was generated by pygo at
+// {{ .Timestamp }}
+{{- end }}
+{{- block "go_header" . }}{{ end }}
+{{- if .BuildTag }}
+
+//go:build {{ .BuildTag }}
+{{- end }}
+
+package main
+
+/*
+#include <stdlib.h>
+typedef long long CInt64;
+typedef struct { void *data; CInt64 len; CInt64 cap; } CSlice, *CSliceP;
+*/
+import "C"
+
+import (
+    "fmt"
+    "unsafe"
+
+	"{{ .Mod.Import }}"
+{{- range .Imports }}
+	{{ . }}
+{{- end }}
+{{- block "go_imports" . }}{{ end }}
+)
+
+{{- range $f := .Funcs }}
+
+//export {{ $f.Name}}
+func {{ $f.Name}}({{$f.GoSigArgs}}) {{$f.GoSigRet}} {
+	{{- block "go_prelude" $f }}{{ end }}
+	{{ if $f.IsVoid -}}
+         {{ $f.GoFuncCall }}
+    {{- else if $f.Result.IsArray -}}
+        {{ template "res_to_slice.go.tmpl" $f }}
+    {{- else -}}
+        {{ $f.ReturnConvertedResult }}
+    {{- end }}
+}
+
+{{- end }}
+
+func StringToError(err string) error {
+	if err == "" {
+		return nil
+	}
+
+	return fmt.Errorf("%s", err)
+}
+
+func handleError(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
+
+//export freeMem
+func freeMem(c *C.void){
+    C.free(unsafe.Pointer(c))
+}
+
+{{- block "go_footer" . }}{{ end }}
+
+func main() {}
+`,
+
+	pyTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- block "py_header" . }}{{ end }}
+{{- if .Doc }}
+{{ .Doc }}
+{{- end }}
+from pygo import gofunc
+{{- block "py_imports" . }}{{ end }}
+
+__all__ = [
+{{- range $name := .All }}
+    "{{ $name }}",
+{{- end }}
+]
+
+{{- template "structs.py.tmpl" . }}
+
+{{- range $f := .Funcs }}
+
+
+@gofunc({{ $f.PyDecoratorArgs }})
+def {{ $f.PyName }}({{$f.PySig}}):
+{{- if $f.Doc }}
+{{ $f.PyDoc }}
+{{- else }} pass
+{{- end }}
+{{- end }}
+{{- block "py_footer" . }}{{ end }}
+`,
+
+	resToSliceTemplate: `
+    res := {{ .ResultCall }}
+    s := len(res)
+    p := C.malloc(C.size_t(s) * C.size_t(unsafe.Sizeof(uintptr(0))))
+    cslice := (C.CSliceP)(C.malloc(C.sizeof_CSlice))
+    cslice.len = C.CInt64(s)
+    cslice.cap = C.CInt64(s)
+    cslice.data = p
+    pp := (*[1<<30 - 1]{{ .Result.T.ToCType }})(p)
+    copy(pp[:], res)
+    ptr := (C.CSliceP)(unsafe.Pointer(cslice))
+    return ptr
+`,
+
+	pyStructsTemplate: `{{- range $s := .Structs }}
+
+
+class {{ $s.PyName }}(object):
+{{- if $s.Doc }}
+{{ $s.PyDoc }}
+{{- end }}
+
+    def __init__({{ $s.PyInitArgs }}):
+        {{- if not $s.Fields }}
+        pass
+        {{- end }}
+        {{- range $field := $s.Fields }}
+        {{- if $field.ReadOnly }}
+        self._{{ $field.PyName }} = {{ $field.PyName }}
+        {{- else }}
+        self.{{ $field.PyName }} = {{ $field.PyName }}
+        {{- end }}
+        {{- end }}
+    {{- range $field := $s.Fields }}
+    {{- if $field.ReadOnly }}
+
+    @property
+    def {{ $field.PyName }}(self):
+        return self._{{ $field.PyName }}
+    {{- end }}
+    {{- end }}
+{{- end }}`,
+
+	extTemplate: `// Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+// This file was generated by pygo at
+// {{ .Timestamp }}
+{{- end }}
+{{- block "c_header" . }}{{ end }}
+
+#define PY_SSIZE_T_CLEAN
+#include <Python.h>
+#include <stdlib.h>
+#include "{{ .Header }}"
+{{- block "c_includes" . }}{{ end }}
+
+#if defined(__GNUC__)
+#define PYGO_UNUSED __attribute__((unused))
+#else
+#define PYGO_UNUSED
+#endif
+
+// the pygo_to_<type> converters set a go value from a python object, and return 0 on error
+PYGO_UNUSED static int pygo_to_int(PyObject *obj, void *p)
+{
+    long long v = PyLong_AsLongLong(obj);
+    if (v == -1 && PyErr_Occurred()) {
+        return 0;
+    }
+    *(GoInt *)p = (GoInt)v;
+    return 1;
+}
+
+PYGO_UNUSED static int pygo_to_int64(PyObject *obj, void *p)
+{
+    long long v = PyLong_AsLongLong(obj);
+    if (v == -1 && PyErr_Occurred()) {
+        return 0;
+    }
+    *(GoInt64 *)p = (GoInt64)v;
+    return 1;
+}
+
+PYGO_UNUSED static int pygo_to_int32(PyObject *obj, void *p)
+{
+    long long v = PyLong_AsLongLong(obj);
+    if (v == -1 && PyErr_Occurred()) {
+        return 0;
+    }
+    if (v < INT32_MIN || v > INT32_MAX) {
+        PyErr_SetString(PyExc_OverflowError, "int is out of the range of int32");
+        return 0;
+    }
+    *(GoInt32 *)p = (GoInt32)v;
+    return 1;
+}
+
+PYGO_UNUSED static int pygo_to_byte(PyObject *obj, void *p)
+{
+    long v = PyLong_AsLong(obj);
+    if (v == -1 && PyErr_Occurred()) {
+        return 0;
+    }
+    if (v < 0 || v > 255) {
+        PyErr_SetString(PyExc_OverflowError, "int is out of the range of byte");
+        return 0;
+    }
+    *(GoUint8 *)p = (GoUint8)v;
+    return 1;
+}
+
+PYGO_UNUSED static int pygo_to_bool(PyObject *obj, void *p)
+{
+    int v = PyObject_IsTrue(obj);
+    if (v < 0) {
+        return 0;
+    }
+    *(GoUint8 *)p = (GoUint8)v;
+    return 1;
+}
+
+// pygo_to_string points the go string to the utf-8 buffer of a python str, which lives as long as it
+PYGO_UNUSED static int pygo_to_string(PyObject *obj, void *p)
+{
+    Py_ssize_t n;
+    const char *s = PyUnicode_AsUTF8AndSize(obj, &n);
+    if (s == NULL) {
+        return 0;
+    }
+    ((GoString *)p)->p = s;
+    ((GoString *)p)->n = n;
+    return 1;
+}
+
+// pygo_to_slice converts a python sequence to a go slice, whose elems of the given size are set by conv.
+// items holds the items of the sequence until the slice is freed by pygo_free_slice, even on error.
+PYGO_UNUSED static int pygo_to_slice(PyObject *obj, GoSlice *s, PyObject **items, size_t size, int (*conv)(PyObject *, void *))
+{
+    Py_ssize_t i, n;
+
+    *items = PySequence_Tuple(obj);
+    if (*items == NULL) {
+        return 0;
+    }
+    n = PyTuple_GET_SIZE(*items);
+    s->data = malloc(n > 0 ? n * size : 1);
+    if (s->data == NULL) {
+        PyErr_NoMemory();
+        return 0;
+    }
+    for (i = 0; i < n; i++) {
+        if (!conv(PyTuple_GET_ITEM(*items, i), (char *)s->data + i * size)) {
+            return 0;
+        }
+    }
+    s->len = n;
+    s->cap = n;
+    return 1;
+}
+
+PYGO_UNUSED static void pygo_free_slice(GoSlice *s, PyObject *items)
+{
+    free(s->data);
+    Py_XDECREF(items);
+}
+
+// the pygo_from_<type> converters return the python object of a go value, or NULL on error
+PYGO_UNUSED static PyObject *pygo_none(void)
+{
+    Py_RETURN_NONE;
+}
+
+PYGO_UNUSED static PyObject *pygo_from_int(const void *p)
+{
+    return PyLong_FromLongLong(*(const GoInt *)p);
+}
+
+PYGO_UNUSED static PyObject *pygo_from_int64(const void *p)
+{
+    return PyLong_FromLongLong(*(const GoInt64 *)p);
+}
+
+PYGO_UNUSED static PyObject *pygo_from_int32(const void *p)
+{
+    return PyLong_FromLong(*(const GoInt32 *)p);
+}
+
+PYGO_UNUSED static PyObject *pygo_from_byte(const void *p)
+{
+    return PyLong_FromLong(*(const GoUint8 *)p);
+}
+
+PYGO_UNUSED static PyObject *pygo_from_bool(const void *p)
+{
+    return PyBool_FromLong(*(const GoUint8 *)p);
+}
+
+// pygo_from_string returns the python str of a C string returned by go, and frees it
+PYGO_UNUSED static PyObject *pygo_from_string(char *s)
+{
+    PyObject *obj = PyUnicode_FromString(s);
+    free(s);
+    return obj;
+}
+
+// pygo_from_error raises a RuntimeError with the message of a C string returned by go, and frees it.
+// It returns None if there's no error.
+PYGO_UNUSED static PyObject *pygo_from_error(char *err)
+{
+    if (err == NULL) {
+        Py_RETURN_NONE;
+    }
+    PyErr_SetString(PyExc_RuntimeError, err);
+    free(err);
+    return NULL;
+}
+
+// pygo_from_slice returns the python list of a C slice returned by go, whose elems of the given size
+// are converted by conv, and frees it
+PYGO_UNUSED static PyObject *pygo_from_slice(CSliceP s, size_t size, PyObject *(*conv)(const void *))
+{
+    Py_ssize_t i;
+    PyObject *list = PyList_New(s->len);
+
+    for (i = 0; list != NULL && i < s->len; i++) {
+        PyObject *item = conv((const char *)s->data + i * size);
+        if (item == NULL) {
+            Py_CLEAR(list);
+            break;
+        }
+        PyList_SET_ITEM(list, i, item);
+    }
+    free(s->data);
+    free(s);
+    return list;
+}
+
+{{- range $f := .Funcs }}
+
+static PyObject *pygo_{{ $f.Name }}(PyObject *self, PyObject *args, PyObject *kwargs)
+{
+    static char *kwlist[] = { {{ $f.CKwList }} };
+    {{- range $a := $f.Args }}
+    {{- if $a.Type.IsArray }}
+    PyObject *py_{{ $a.Name }};
+    PyObject *items_{{ $a.Name }} = NULL;
+    GoSlice go_{{ $a.Name }} = { NULL, 0, 0 };
+    {{- else if eq $a.CType "GoString" }}
+    GoString go_{{ $a.Name }};
+    Py_ssize_t len_{{ $a.Name }};
+    {{- else }}
+    {{ $a.CType }} go_{{ $a.Name }};
+    {{- end }}
+    {{- end }}
+    {{- if not $f.IsVoid }}
+    {{ $f.CResultType }} res;
+    {{- end }}
+    PyObject *ret = NULL;
+    int ok;
+
+    ok = PyArg_ParseTupleAndKeywords(args, kwargs, "{{ $f.CParseFormat }}", kwlist{{ $f.CParseArgs }});
+    {{- range $a := $f.Args }}
+    {{- if $a.Type.IsArray }}
+    ok = ok && pygo_to_slice(py_{{ $a.Name }}, &go_{{ $a.Name }}, &items_{{ $a.Name }}, sizeof({{ $a.CElemType }}), pygo_to_{{ $a.Type.T }});
+    {{- end }}
+    {{- end }}
+    if (ok) {
+        {{- range $a := $f.Args }}
+        {{- if and (not $a.Type.IsArray) (eq $a.CType "GoString") }}
+        go_{{ $a.Name }}.n = len_{{ $a.Name }};
+        {{- end }}
+        {{- end }}
+        {{- if not $f.HoldGil }}
+        Py_BEGIN_ALLOW_THREADS
+        {{- end }}
+        {{ if not $f.IsVoid }}res = {{ end }}{{ $f.Name }}({{ $f.CCallArgs }});
+        {{- if not $f.HoldGil }}
+        Py_END_ALLOW_THREADS
+        {{- end }}
+        ret = {{ $f.CResult }};
+    }
+    {{- range $a := $f.Args }}
+    {{- if $a.Type.IsArray }}
+    pygo_free_slice(&go_{{ $a.Name }}, items_{{ $a.Name }});
+    {{- end }}
+    {{- end }}
+    return ret;
+}
+{{- end }}
+
+static PyMethodDef pygo_methods[] = {
+{{- range $f := .Funcs }}
+    { "{{ $f.PyName }}", (PyCFunction)(void (*)(void))pygo_{{ $f.Name }}, METH_VARARGS | METH_KEYWORDS, {{ $f.CDoc }} },
+{{- end }}
+    { NULL, NULL, 0, NULL }
+};
+
+// the funcs are added to the module by their go names, as their python names are only unique in their python module
+static const char *pygo_names[] = {
+{{- range $f := .Funcs }}
+    "{{ $f.Name }}",
+{{- end }}
+    NULL
+};
+
+static struct PyModuleDef pygo_module = {
+    PyModuleDef_HEAD_INIT, "{{ .Module }}", NULL, -1, NULL,
+};
+
+PyMODINIT_FUNC PyInit_{{ .Module }}(void)
+{
+    PyObject *m, *name;
+    int i;
+
+    m = PyModule_Create(&pygo_module);
+    if (m == NULL) {
+        return NULL;
+    }
+    name = PyModule_GetNameObject(m);
+    if (name == NULL) {
+        Py_DECREF(m);
+        return NULL;
+    }
+    for (i = 0; pygo_names[i] != NULL; i++) {
+        PyObject *f = PyCFunction_NewEx(&pygo_methods[i], m, name);
+        if (f == NULL || PyModule_AddObject(m, pygo_names[i], f) < 0) {
+            Py_XDECREF(f);
+            Py_DECREF(name);
+            Py_DECREF(m);
+            return NULL;
+        }
+    }
+    Py_DECREF(name);
+    return m;
+}
+{{- block "c_footer" . }}{{ end }}
+`,
+
+	pyExtTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- template "py_header" . }}
+{{- if .Doc }}
+{{ .Doc }}
+{{- end }}
+from pygo import load_ext
+{{- template "py_imports" . }}
+
+__all__ = [
+{{- range $name := .All }}
+    "{{ $name }}",
+{{- end }}
+]
+
+_ext = load_ext(__file__, "{{ .Ext }}")
+{{- template "structs.py.tmpl" . }}
+
+{{- range $f := .Funcs }}
+{{- if $f.HasDefaults }}
+
+
+def {{ $f.PyName }}({{ $f.PySig }}):
+{{- if $f.Doc }}
+{{ $f.PyDoc }}
+{{- end }}
+    return _ext.{{ $f.Name }}({{ $f.PyArgs }})
+{{- else }}
+
+
+{{ $f.PyName }} = _ext.{{ $f.Name }}
+{{- end }}
+{{- end }}
+{{- template "py_footer" . }}
+`,
+
+	cffiBuildTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- block "cffi_header" . }}{{ end }}
+"""Builds {{ .Module }}, the cffi extension module of {{ .SharedLib }}."""
+import os
+import sys
+import sysconfig
+import tempfile
+
+from cffi import FFI
+
+_dir = os.path.dirname(os.path.abspath(__file__))
+
+# the shared lib isn't named lib<name>.so, so it's linked by file name with GNU ld, and by path on macOS
+if sys.platform.startswith("linux"):
+    _link_args = ["-l:{{ .SharedLib }}", "-Wl,-rpath,$ORIGIN"]
+elif sys.platform == "darwin":
+    _link_args = [os.path.join(_dir, "{{ .SharedLib }}"), "-Wl,-rpath,@loader_path"]
+else:
+    sys.exit("{{ .Module }} can't be built on %s, only on linux and macOS" % sys.platform)
+
+ffibuilder = FFI()
+ffibuilder.cdef("""
+typedef int... GoInt;
+typedef int... GoInt32;
+typedef int... GoInt64;
+typedef int... GoUint8;
+typedef int... CInt64;
+typedef struct { const char *p; ptrdiff_t n; ...; } GoString;
+typedef struct { void *data; GoInt len; GoInt cap; ...; } GoSlice;
+typedef struct { void *data; CInt64 len; CInt64 cap; ...; } CSlice, *CSliceP;
+{{- range $f := .Funcs }}
+{{ $f.CffiDecl }}
+{{- end }}
+void freeMem(void *);
+{{- block "cffi_cdef" . }}{{ end }}
+""")
+ffibuilder.set_source(
+    "{{ .Module }}",
+    '#include "{{ .Header }}"',
+    include_dirs=[_dir],
+    library_dirs=[_dir],
+    extra_link_args=_link_args,
+)
+{{- block "cffi_footer" . }}{{ end }}
+
+if __name__ == "__main__":
+    # only the extension module is written next to the shared lib, with the suffix of the python interpreter
+    target = os.path.join(_dir, "{{ .Module }}" + sysconfig.get_config_var("EXT_SUFFIX"))
+    with tempfile.TemporaryDirectory() as tmpdir:
+        ffibuilder.compile(tmpdir=tmpdir, target=target)
+`,
+
+	pyCffiTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- template "py_header" . }}
+{{- if .Doc }}
+{{ .Doc }}
+{{- end }}
+from pygo import gocffi, load_ext
+{{- template "py_imports" . }}
+
+__all__ = [
+{{- range $name := .All }}
+    "{{ $name }}",
+{{- end }}
+]
+
+_ext = load_ext(__file__, "{{ .Ext }}")
+_ffi, _lib = _ext.ffi, _ext.lib
+{{- template "structs.py.tmpl" . }}
+
+{{- range $f := .Funcs }}
+
+
+def {{ $f.PyName }}({{ $f.PySig }}):
+{{- if $f.Doc }}
+{{ $f.PyDoc }}
+{{- end }}
+{{- if $f.CffiNeedsArgs }}
+    _args = gocffi.Args(_ffi)
+{{- end }}
+    return {{ $f.CffiResult }}
+{{- end }}
+{{- template "py_footer" . }}
+`,
+
+	pyStubTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- block "pyi_header" . }}{{ end }}
+{{- if .Doc }}
+{{ .Doc }}
+{{- end }}
+{{- if .Typing }}
+from typing import {{ join .Typing ", " }}
+{{- end }}
+{{- block "pyi_imports" . }}{{ end }}
+
+__all__ = [
+{{- range $name := .All }}
+    "{{ $name }}",
+{{- end }}
+]
+
+{{- range $s := .Structs }}
+
+
+class {{ $s.PyName }}:
+{{- if $s.Doc }}
+{{ $s.PyDoc }}
+{{- end }}
+{{- range $field := $s.Fields }}
+{{- if not $field.ReadOnly }}
+    {{ $field.PyName }}: {{ $field.PyHint }}
+{{- end }}
+{{- end }}
+
+    def __init__({{ $s.PyStubInitArgs }}) -> None: ...
+{{- range $field := $s.Fields }}
+{{- if $field.ReadOnly }}
+
+    @property
+    def {{ $field.PyName }}(self) -> {{ $field.PyHint }}: ...
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- range $f := .Funcs }}
+
+
+def {{ $f.PyName }}({{ $f.PyStubSig }}) -> {{ $f.PyResultHint }}:
+{{- if $f.Doc }}
+{{ $f.PyDoc }}
+{{- else }} ...
+{{- end }}
+{{- end }}
+{{- block "pyi_footer" . }}{{ end }}
+`,
+
+	pyprojectTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- block "pyproject_header" . }}{{ end }}
+
+[build-system]
+requires = ["setuptools>=61"]
+build-backend = "setuptools.build_meta"
+
+[project]
+name = "{{ .Name }}"
+version = "{{ .Version }}"
+dependencies = [
+{{- range .Requires }}
+    "{{ . }}",
+{{- end }}
+]
+{{- block "pyproject_project" . }}{{ end }}
+
+[tool.setuptools]
+packages = ["{{ .Name }}"]
+
+[tool.setuptools.package-data]
+{{ .Name }} = ["*.so", "*.pyi", "py.typed"]
+
+[tool.pygo]
+# the tag of the wheel built by pygo package, for which the shared libs are built
+tag = "{{ .Tag }}"
+{{- block "pyproject_footer" . }}{{ end }}
+`,
+
+	pyInitTemplate: `# Code generated by pygo; DO NOT EDIT.
+{{- if .Timestamp }}
+# This file was generated by pygo at
+# {{ .Timestamp }}
+{{- end }}
+{{- block "init_header" . }}{{ end }}
+
+__version__ = "{{ .Version }}"
+
+__all__ = [
+{{- range .Modules }}
+    "{{ . }}",
+{{- end }}
+]
+{{- block "init_footer" . }}{{ end }}
+`,
+}
+
+// templateFuncs are the helper funcs of the templates
+var templateFuncs = template.FuncMap{
+	"contains":   strings.Contains,
+	"env":        os.Getenv,
+	"hasPrefix":  strings.HasPrefix,
+	"hasSuffix":  strings.HasSuffix,
+	"join":       strings.Join,
+	"lower":      strings.ToLower,
+	"prefix":     prefixLines,
+	"quote":      strconv.Quote,
+	"replace":    strings.ReplaceAll,
+	"split":      strings.Split,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+	"upper":      strings.ToUpper,
+}
+
+// goFileData is the data of the template of the go file of a lib
+type goFileData struct {
+	// Timestamp is the time of the generation with -timestamp, empty otherwise
+	Timestamp string
+	// Funcs are the exported funcs of the lib
+	Funcs []*libfunc.Func
+	// Lib is the name of the lib, i.e. of the go package
+	Lib string
+	// Dir is the dir the file is generated in
+	Dir string
+	// Mod is the module of the go package
+	Mod *ast.Mod
+	// Imports are the import specs of the packages the funcs refer to, e.g. model "github.com/x/model"
+	Imports []string
+	// BuildTag is the build constraint of the generated go files, if any
+	BuildTag string
+}
+
+// pyFileData is the data of the template of a python module of a lib
+type pyFileData struct {
+	// Timestamp is the time of the generation with -timestamp, empty otherwise
+	Timestamp string
+	// Funcs and Structs are the funcs and structs of the module
+	Funcs   []*libfunc.Func
+	Structs []*libfunc.Struct
+	// Lib is the name of the lib, i.e. of the go package
+	Lib string
+	// Dir is the dir the file is generated in
+	Dir string
+	// Mod is the module of the go package
+	Mod *ast.Mod
+	// Doc is the docstring of the module, from the doc of the go package
+	Doc string
+	// All are the names of the module exported by __all__
+	All []string
+	// Ext is the name of the extension module the python module imports with -backend=cpython or cffi
+	Ext string
+	// Typing are the names the stub of the module imports from typing
+	Typing []string
+}
+
+// extFileData is the data of the templates of the source of the extension module of a lib,
+// the C extension module or the cffi build script
+type extFileData struct {
+	// Timestamp is the time of the generation with -timestamp, empty otherwise
+	Timestamp string
+	// Funcs are the exported funcs of the lib
+	Funcs []*libfunc.Func
+	// Lib is the name of the lib, i.e. of the go package
+	Lib string
+	// Dir is the dir the file is generated in
+	Dir string
+	// Mod is the module of the go package
+	Mod *ast.Mod
+	// Module is the name of the extension module
+	Module string
+	// Header is the file name of the C header of the shared lib, which declares the cgo wrappers
+	Header string
+	// SharedLib is the file name of the shared lib the extension module is linked with
+	SharedLib string
+}
+
+// packageFileData is the data of the templates of the files of the python package laid out by pygo package
+type packageFileData struct {
+	// Timestamp is the time of the generation with -timestamp, empty otherwise
+	Timestamp string
+	// Name and Version are the name and the version of the python package
+	Name    string
+	Version string
+	// Tag is the tag of the wheel, e.g. py3-none-linux_x86_64
+	Tag string
+	// Modules are the python modules of the package
+	Modules []string
+	// Requires are the python packages the package depends on
+	Requires []string
+}
+
+// loadTemplates returns the templates of the generated files: the default ones,
+// overridden or completed by the *.tmpl files of dir if it's set, by file name.
+func loadTemplates(dir string) (*template.Template, error) {
+	set := template.New("").Funcs(templateFuncs)
+	names := make([]string, 0, len(defaultTemplates))
+	for name := range defaultTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := set.New(name).Parse(defaultTemplates[name]); err != nil {
+			return nil, fmt.Errorf("Couldn't parse template %s: %v", name, err)
+		}
+	}
+	if dir == "" {
+		return set, nil
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("Couldn't read templates dir: %v", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read template %s: %v", path, err)
+		}
+		if _, err := set.New(filepath.Base(path)).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("Couldn't parse template %s: %v", path, err)
+		}
+	}
+	return set, nil
+}
+
+// prefixLines prefixes the lines of s, e.g. {{ prefix "// " .Doc }}
+func prefixLines(prefix, s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkHeader checks that a generated file starts with the header of pygo, which the overridden templates must keep,
+// as the generated files are recognized by it, e.g. to be cleaned
+func checkHeader(f *genFile) error {
+	line := string(f.Content)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if !pygoHeaderRe.MatchString(strings.TrimSuffix(line, "\r")) {
+		return fmt.Errorf("the first line must be a %q comment, was %q", "Code generated by pygo; DO NOT EDIT.", line)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanndegat/pygo/internal/ast"
+	"github.com/yanndegat/pygo/internal/libfunc"
+)
+
+func TestMain_loadTemplates(t *testing.T) {
+	tests := []struct {
+		Files map[string]string
+		// Contains is a line of the rendered python module, empty if the templates are invalid
+		Contains string
+		Err      string
+	}{
+		{nil, "from pygo import gofunc", ""},
+		{map[string]string{"hooks.tmpl": `{{ define "py_header" }}{{ "\n" }}# (c) {{ upper .Lib }}{{ end }}`}, "# (c) MYLIB", ""},
+		{map[string]string{"lib.py.tmpl": "# Code generated by pygo; DO NOT EDIT.\n{{ prefix \"# \" (join .All \"\\n\") }}\n"}, "# f", ""},
+		{map[string]string{"lib.py.tmpl": "{{ .Unknown"}, "", "Couldn't parse template"},
+		{map[string]string{"lib.py.tmpl": "{{ nope }}"}, "", `function "nope" not defined`},
+		{map[string]string{"lib.py.tmpl": "{{ .Unknown }}"}, "", "can't evaluate field Unknown"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "pygo")
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, content := range test.Files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("%v", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			tpls, err := loadTemplates(dir)
+			if err == nil {
+				err = tpls.ExecuteTemplate(&buf, pyTemplate, &pyFileData{Lib: "mylib", Mod: &ast.Mod{}, All: []string{"f"}})
+			}
+			errStr := ""
+			if err != nil {
+				errStr = err.Error()
+			}
+			if test.Err == "" && errStr != "" || !strings.Contains(errStr, test.Err) {
+				t.Fatalf("match should be %q, was %q", test.Err, errStr)
+			}
+			if test.Contains == "" {
+				return
+			}
+
+			if !strings.Contains(buf.String(), test.Contains+"\n") {
+				t.Fatalf("match should contain %q, was %q", test.Contains, buf.String())
+			}
+			if err := checkHeader(&genFile{Path: "mylib.py", Content: buf.Bytes()}); err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+		})
+	}
+}
+
+func TestMain_renderExt(t *testing.T) {
+	tpls, err := loadTemplates("")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f := &libfunc.Func{Lib: "mylib", Name: "Greet", PyName: "greet", Module: "mylib", SharedLib: "_mylib.so",
+		Args: []libfunc.Arg{{Name: "name", Type: libfunc.TypeString}, {Name: "times", Type: libfunc.TypeInt, Default: "1"}}, Result: libfunc.TypeString}
+	pyLib := libfunc.NewLib("mylib", []*libfunc.Func{f}, nil, "")
+
+	ext, err := renderExt(tpls, "/out", &ast.Mod{}, pyLib, backendCPython, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	py, err := renderPy(tpls, "/out", "mylib", "mylib", backendCPython, &ast.Mod{}, pyLib, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	cffiBuild, err := renderExt(tpls, "/out", &ast.Mod{}, pyLib, backendCffi, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	cffiPy, err := renderPy(tpls, "/out", "mylib", "mylib", backendCffi, &ast.Mod{}, pyLib, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := []struct {
+		File     *genFile
+		Path     string
+		Contains []string
+	}{
+		{ext, "/out/_mylib_ext.c", []string{`#include "_mylib.h"`, "        res = Greet(go_name, go_times);", `    { "greet", (PyCFunction)(void (*)(void))pygo_Greet, METH_VARARGS | METH_KEYWORDS, NULL },`, "PyMODINIT_FUNC PyInit__mylib_ext(void)"}},
+		{py, "/out/mylib.py", []string{`_ext = load_ext(__file__, "_mylib_ext")`, "def greet(name, times=1):", "    return _ext.Greet(name, times)"}},
+		{cffiBuild, "/out/_mylib_cffi_build.py", []string{"char* Greet(GoString, GoInt);", `    "_mylib_cffi",`, `    _link_args = ["-l:_mylib.so", "-Wl,-rpath,$ORIGIN"]`, `    _link_args = [os.path.join(_dir, "_mylib.so"), "-Wl,-rpath,@loader_path"]`, `    extra_link_args=_link_args,`}},
+		{cffiPy, "/out/mylib.py", []string{`_ext = load_ext(__file__, "_mylib_cffi")`, "def greet(name, times=1):", "    _args = gocffi.Args(_ffi)", "    return gocffi.from_string(_ffi, _lib, _lib.Greet(_args.string(name), times))"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if test.File.Path != test.Path {
+				t.Fatalf("match should be %v, was %v", test.Path, test.File.Path)
+			}
+			if err := checkHeader(test.File); err != nil {
+				t.Fatalf("match should be %v, was %v", nil, err)
+			}
+			for _, line := range test.Contains {
+				if !strings.Contains(string(test.File.Content), line+"\n") {
+					t.Fatalf("match should contain %q, was %q", line, test.File.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestMain_renderStub(t *testing.T) {
+	tpls, err := loadTemplates("")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f := &libfunc.Func{Lib: "mylib", Name: "Greet", PyName: "greet", Module: "mylib",
+		Args: []libfunc.Arg{{Name: "names", Type: "[]string"}, {Name: "times", Type: libfunc.TypeInt, Default: "1"}}, Result: libfunc.TypeError}
+	s := &libfunc.Struct{Lib: "mylib", Name: "Person", PyName: "Person", Module: "mylib",
+		Fields: []libfunc.Field{{Name: "Name", PyName: "name", Type: libfunc.TypeString}, {Name: "ID", PyName: "id", Type: libfunc.TypeInt, ReadOnly: true}}}
+	pyLib := libfunc.NewLib("mylib", []*libfunc.Func{f}, []*libfunc.Struct{s}, "")
+
+	stub, err := renderStub(tpls, "/out", "mylib", "mylib", &ast.Mod{}, pyLib, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if stub.Path != "/out/mylib.pyi" {
+		t.Fatalf("match should be %v, was %v", "/out/mylib.pyi", stub.Path)
+	}
+	if err := checkHeader(stub); err != nil {
+		t.Fatalf("match should be %v, was %v", nil, err)
+	}
+	for _, line := range []string{
+		"from typing import Optional",
+		"class Person:",
+		"    name: Optional[str]",
+		"    def __init__(self, name: Optional[str] = None, id: Optional[int] = None) -> None: ...",
+		"    def id(self) -> Optional[int]: ...",
+		"def greet(names: list[str], times: int = 1) -> Optional[bytes]: ...",
+	} {
+		if !strings.Contains(string(stub.Content), line+"\n") {
+			t.Fatalf("match should contain %q, was %q", line, stub.Content)
+		}
+	}
+}
+
+func TestMain_renderPackageFile(t *testing.T) {
+	tpls, err := loadTemplates("")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	data := &packageFileData{Name: "mylib", Version: "1.2.0", Tag: "py3-none-linux_x86_64", Modules: []string{"extra", "mylib"}, Requires: []string{"pygo"}}
+
+	tests := []struct {
+		Name  string
+		File  string
+		Lines []string
+	}{
+		{
+			pyprojectTemplate,
+			pyprojectFile,
+			[]string{`name = "mylib"`, `version = "1.2.0"`, `    "pygo",`, `packages = ["mylib"]`, `tag = "py3-none-linux_x86_64"`},
+		},
+		{
+			pyInitTemplate,
+			"__init__.py",
+			[]string{`__version__ = "1.2.0"`, `    "extra",`, `    "mylib",`},
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			content, err := renderPackageFile(tpls, test.Name, test.File, data)
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			for _, line := range test.Lines {
+				if !strings.Contains(string(content), line+"\n") {
+					t.Fatalf("match should contain %q, was %q", line, content)
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,19 @@
 import unittest
 import ctypes
+import inspect
+import os
+import shutil
+import tempfile
 import time
 
 from pygo import gofunc, GoString, _map_ctype
+from pygo.gofunc import _load_lib
 
 # package name is different from dir path on purpose
 from mylibgo.pygo import mygolib
+from mylibgo.pygo import extra
+from mylibgo.allgo.pygo import allgo
+from mylibgo.native.pygo import native
 
 
 class GoFuncTestCase(unittest.TestCase):
@@ -34,6 +42,15 @@ class GoFuncTestCase(unittest.TestCase):
         result = test1("world".encode('utf-8'))
         self.assertEqual(result.decode('utf-8'), "hello world")
 
+    def test_load_lib_by_path(self):
+        """Test libs of the same name in different dirs aren't mixed up"""
+        here = os.path.dirname(os.path.abspath(__file__))
+        with tempfile.TemporaryDirectory() as tmpdir:
+            shutil.copy(os.path.join(here, "simplelib.so"), tmpdir)
+            lib = _load_lib(here, "simplelib.so")
+            self.assertIs(_load_lib(here, "simplelib.so"), lib)
+            self.assertIsNot(_load_lib(tmpdir, "simplelib.so"), lib)
+
     def test_func_args(self):
         """Test call go func"""
 
@@ -109,6 +126,80 @@ class GoFuncTestCase(unittest.TestCase):
         bools = [True, True, True, False, False, True]
         self.assertEqual(mygolib.Test7(bools, bools), bools+bools)
 
+    def test_mylibgo_tagged_struct(self):
+        """Test generated python class"""
+        s = mygolib.MyTaggedStruct(a_string="hello", an_int=4, id=2)
+        self.assertEqual(s.a_string, "hello")
+        self.assertEqual(s.an_int, 4)
+        self.assertEqual(s.id, 2)
+        self.assertFalse(hasattr(s, "Hidden"))
+        self.assertFalse(hasattr(s, "internal"))
+        with self.assertRaises(AttributeError):
+            s.id = 3
+
+    def test_mylibgo_export_options(self):
+        """Test call go func exported with options"""
+        self.assertFalse(hasattr(mygolib, "Test11"))
+        self.assertEqual(extra.renamed("world"), "hello world")
+
+    def test_allgo_exportall(self):
+        """Test call go func exported by @pygo.exportall"""
+        self.assertEqual(allgo.Hello("world"), "hello world")
+        self.assertEqual(allgo.Upper("world"), "WORLD")
+        self.assertFalse(hasattr(allgo, "Skipped"))
+        self.assertFalse(hasattr(allgo, "Variadic"))
+
+    def test_allgo_docstrings(self):
+        """Test go doc comments are python docstrings"""
+        self.assertEqual(allgo.__doc__, "Package allgo exports all its supported funcs.")
+        self.assertEqual(allgo.__all__, ["Hello", "Upper"])
+        self.assertEqual(inspect.getdoc(allgo.Hello), "Hello greets name.\n\n"
+                         "It's exported because of the exportall directive of the package.")
+        self.assertEqual(allgo.Hello.__name__, "Hello")
+        self.assertIsNone(allgo.Upper.__doc__)
+
+    def test_mylibgo_kwargs(self):
+        """Test call go func with keyword args"""
+        self.assertEqual(mygolib.Test3(arg1="hello", arg2="world", arg3=42), "hello world 42")
+        self.assertEqual(mygolib.Test3("hello", arg3=42, arg2="world"), "hello world 42")
+        with self.assertRaises(TypeError):
+            mygolib.Test3("hello", arg3=42)
+
+    def test_mylibgo_defaults(self):
+        """Test call go func with default values"""
+        self.assertEqual(mygolib.Test12("world"), "hello world")
+        self.assertEqual(mygolib.Test12("world", times=2), "hello worldhello world")
+        self.assertEqual(mygolib.Test12(from_="world", greeting="hi"), "hi world")
+
+    def test_named_types(self):
+        """Test call go func with named types, converted to their underlying types"""
+        self.assertEqual(mygolib.Test13("id", [1, 2, 3]), "id-3")
+        self.assertEqual(mygolib.Test14(21), 42)
+
+    def test_config(self):
+        """Test call go func with a type converter and settings from pygo.json"""
+        self.assertEqual(mygolib.add_days("2021-03-04"), "2021-03-05")
+        self.assertEqual(mygolib.add_days("2021-03-04", days=10), "2021-03-14")
+        self.assertFalse(hasattr(mygolib, "Test15"))
+
+    def test_cpython_backend(self):
+        """Test call go func from a C extension module"""
+        self.assertEqual(native.Join(["hello", "world"], " "), "hello world")
+        self.assertEqual(native.Join(words=[], sep=" "), "")
+        self.assertEqual(native.Sum([1, 2, 2**40]), 3 + 2**40)
+        self.assertEqual(native.Sum(range(4)), 6)
+        self.assertEqual(native.Repeat(3, 255), [255, 255, 255])
+        self.assertEqual(native.Not([True, False]), [False, True])
+        self.assertIsNone(native.Check(1))
+        with self.assertRaisesRegex(RuntimeError, "-1 is negative"):
+            native.Check(-1)
+        with self.assertRaises(OverflowError):
+            native.Repeat(2**31, 1)
+        with self.assertRaises(TypeError):
+            native.Sum(["1"])
+        self.assertEqual(inspect.getdoc(native.Join), "Join joins the words with sep.")
+        self.assertEqual(native.Join.__name__, "Join")
+
 
 if __name__ == '__main__':
     unittest.main()
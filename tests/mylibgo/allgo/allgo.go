@@ -0,0 +1,33 @@
+// Package allgo exports all its supported funcs.
+// @pygo.exportall
+package allgo
+
+//go:generate pygo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hello greets name.
+//
+// It's exported because of the exportall directive of the package.
+func Hello(name string) string {
+	return fmt.Sprintf("hello %s", name)
+}
+
+func Upper(s string) string {
+	return strings.ToUpper(s)
+}
+
+// @pygo.skip
+func Skipped() {
+}
+
+// not supported, so not exported
+func Variadic(args ...string) string {
+	return strings.Join(args, " ")
+}
+
+func unexported() {
+}
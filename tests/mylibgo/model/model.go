@@ -0,0 +1,33 @@
+// Package model holds types used by the exported funcs of mygolib
+package model
+
+import "fmt"
+
+// ID is a named string, converted from and to a python str
+type ID string
+
+// IDs is a named slice, converted from and to a python list
+type IDs []int
+
+// User is a struct, which can't be passed to an exported func
+type User struct {
+	ID   ID
+	Name string
+}
+
+// Date is a struct, exchanged with python as a YYYY-MM-DD str by the converters of pygo.json
+type Date struct {
+	Year, Month, Day int
+}
+
+// ParseDate parses a YYYY-MM-DD date, the zero date being returned if it's invalid
+func ParseDate(s string) Date {
+	var d Date
+	fmt.Sscanf(s, "%d-%d-%d", &d.Year, &d.Month, &d.Day)
+	return d
+}
+
+// FormatDate formats a date as YYYY-MM-DD
+func FormatDate(d Date) string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
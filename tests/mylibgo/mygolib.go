@@ -1,10 +1,13 @@
 // package name is different from dir path on purpose
 package mygolib
 
-//go:generate pygo
+//go:generate pygo -config pygo.json
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/yanndegat/pygo/tests/mylibgo/model"
 )
 
 type MyStruct struct {
@@ -15,6 +18,17 @@ type MyComplexStruct struct {
 	AStruct *MyStruct
 }
 
+/* this struct is exported
+ * @pygo.export
+ */
+type MyTaggedStruct struct {
+	AString  string `pygo:"a_string"`
+	AnInt    int    `json:"an_int,omitempty"`
+	AnID     int    `pygo:"id,readonly"`
+	Hidden   string `pygo:",omit"`
+	internal string
+}
+
 /* this func is exported
  * @pygo.export
  */
@@ -95,3 +109,43 @@ func Test9(arg MyStruct) MyStruct {
 func Test10(arg MyStruct) *MyComplexStruct {
 	return nil
 }
+
+/* this func is exported under another name, in another python module
+ * @pygo.export(name="renamed", module="extra", gil="hold")
+ */
+func Test11(arg1 string) string {
+	return fmt.Sprintf("hello %s", arg1)
+}
+
+/* this func has default values, and a param named after a python keyword
+ * @pygo.export
+ * @pygo.defaults(greeting="hello", times=1)
+ */
+func Test12(from, greeting string, times int) string {
+	return strings.Repeat(fmt.Sprintf("%s %s", greeting, from), times)
+}
+
+/* this func has params and a result of named types from another package
+ * @pygo.export
+ */
+func Test13(id model.ID, ids model.IDs) model.ID {
+	return model.ID(fmt.Sprintf("%s-%d", id, len(ids)))
+}
+
+/* this func returns a named type of the package
+ * @pygo.export
+ */
+func Test14(n int) Count {
+	return Count(n * 2)
+}
+
+type Count int
+
+/* this func has a param and a result converted by the converters of pygo.json,
+ * which also renames it and sets the default value of days
+ * @pygo.export
+ */
+func Test15(date model.Date, days int) model.Date {
+	date.Day += days
+	return date
+}
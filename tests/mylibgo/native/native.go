@@ -0,0 +1,43 @@
+// Package native is called by a C extension module instead of ctypes.
+// @pygo.exportall
+package native
+
+//go:generate pygo -backend=cpython
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Join joins the words with sep.
+func Join(words []string, sep string) string {
+	return strings.Join(words, sep)
+}
+
+func Sum(values []int64) int64 {
+	sum := int64(0)
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func Repeat(n int32, b byte) []byte {
+	return []byte(strings.Repeat(string([]byte{b}), int(n)))
+}
+
+// Check returns an error if n is negative.
+func Check(n int) error {
+	if n < 0 {
+		return fmt.Errorf("%d is negative", n)
+	}
+	return nil
+}
+
+func Not(values []bool) []bool {
+	res := make([]bool, len(values))
+	for i, v := range values {
+		res[i] = !v
+	}
+	return res
+}
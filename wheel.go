@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// wheelTime is the modification time of the files of the wheels, so that they're reproducible
+var wheelTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// wheelTag returns the tag of the wheel of the libs built with b, for the platform of the python interpreter
+// unless plat is set. The python modules of the ctypes backend run on any python 3,
+// while the extension modules are built for the python interpreter.
+func (b *buildOptions) wheelTag(plat string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(b.Python, "-c", `import sys, sysconfig; print(sys.implementation.name); print("%d%d" % sys.version_info[:2]); print(sysconfig.get_config_var("SOABI") or ""); print(sysconfig.get_platform())`)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if len(b.Env) > 0 {
+		cmd.Env = append(os.Environ(), b.Env...)
+	}
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Couldn't get the platform of %s: %v: %s", b.Python, err, strings.TrimSpace(stderr.String()))
+	}
+	lines := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		return "", fmt.Errorf("Couldn't get the platform of %s: unexpected output %q", b.Python, stdout.String())
+	}
+	impl, version, soabi := lines[0], lines[1], strings.Split(lines[2], "-")
+	if plat == "" {
+		plat = strings.NewReplacer("-", "_", ".", "_").Replace(lines[3])
+	}
+	if !hasExt(b.Backend) {
+		return fmt.Sprintf("py3-none-%s", plat), nil
+	}
+
+	// e.g. cp311-cp311 for the SOABI cpython-311-x86_64-linux-gnu, and pp310-pypy310_pp73 for pypy310-pp73-x86_64-linux-gnu
+	switch {
+	case impl == "cpython" && len(soabi) > 1:
+		return fmt.Sprintf("cp%s-cp%s-%s", version, soabi[1], plat), nil
+	case impl == "pypy" && len(soabi) > 1:
+		return fmt.Sprintf("pp%s-%s_%s-%s", version, soabi[0], soabi[1], plat), nil
+	}
+	return "", fmt.Errorf("Couldn't get the platform of %s: unsupported python implementation %s, with SOABI %q", b.Python, impl, lines[2])
+}
+
+// wheelName returns the file name of the wheel of a python package
+func wheelName(data *packageFileData) string {
+	return fmt.Sprintf("%s-%s-%s.whl", data.Name, data.Version, data.Tag)
+}
+
+// writeWheel writes the wheel of a python package at path, with the files of the package, whose paths are relative
+// to the root of the wheel, and its dist-info: its METADATA, WHEEL and RECORD files.
+func writeWheel(path string, files []*genFile, data *packageFileData) error {
+	distInfo := fmt.Sprintf("%s-%s.dist-info", data.Name, data.Version)
+
+	var metadata strings.Builder
+	fmt.Fprintf(&metadata, "Metadata-Version: 2.1\nName: %s\nVersion: %s\n", data.Name, data.Version)
+	for _, r := range data.Requires {
+		fmt.Fprintf(&metadata, "Requires-Dist: %s\n", r)
+	}
+	wheel := fmt.Sprintf("Wheel-Version: 1.0\nGenerator: pygo (%s)\nRoot-Is-Purelib: false\nTag: %s\n", pygoVersion(), data.Tag)
+
+	files = append(append([]*genFile{}, files...),
+		&genFile{Path: distInfo + "/METADATA", Content: []byte(metadata.String())},
+		&genFile{Path: distInfo + "/WHEEL", Content: []byte(wheel)},
+	)
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	// RECORD lists the hash and the size of each file, but its own
+	var record strings.Builder
+	for _, f := range files {
+		sum := sha256.Sum256(f.Content)
+		fmt.Fprintf(&record, "%s,sha256=%s,%d\n", f.Path, base64.RawURLEncoding.EncodeToString(sum[:]), len(f.Content))
+	}
+	fmt.Fprintf(&record, "%s/RECORD,,\n", distInfo)
+	files = append(files, &genFile{Path: distInfo + "/RECORD", Content: []byte(record.String())})
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		h := &zip.FileHeader{Name: f.Path, Method: zip.Deflate, Modified: wheelTime}
+		h.SetMode(0644)
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			return fmt.Errorf("Couldn't write wheel %s: %v", path, err)
+		}
+		if _, err := fw.Write(f.Content); err != nil {
+			return fmt.Errorf("Couldn't write wheel %s: %v", path, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Couldn't write wheel %s: %v", path, err)
+	}
+
+	// the wheel is renamed once written, so that a failed build doesn't leave a truncated one
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Couldn't write file %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("Couldn't write wheel %s: %v", path, err)
+	}
+	return nil
+}
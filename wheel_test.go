@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain_writeWheel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pygo")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := &packageFileData{Name: "mylib", Version: "1.0.0", Tag: "py3-none-linux_x86_64", Requires: []string{"pygo"}}
+	if expected := "mylib-1.0.0-py3-none-linux_x86_64.whl"; wheelName(data) != expected {
+		t.Fatalf("match should be %v, was %v", expected, wheelName(data))
+	}
+	files := []*genFile{
+		{Path: "mylib/lib.py", Content: []byte("print()\n")},
+		{Path: "mylib/__init__.py", Content: []byte("")},
+	}
+	path := filepath.Join(dir, wheelName(data))
+	if err := writeWheel(path, files, data); err != nil {
+		t.Fatalf("%v", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	names := []string{}
+	contents := map[string]string{}
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		contents[f.Name] = string(b)
+	}
+	expected := []string{"mylib-1.0.0.dist-info/METADATA", "mylib-1.0.0.dist-info/WHEEL", "mylib/__init__.py", "mylib/lib.py", "mylib-1.0.0.dist-info/RECORD"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("match should be %v, was %v", expected, names)
+	}
+	if expected := "Metadata-Version: 2.1\nName: mylib\nVersion: 1.0.0\nRequires-Dist: pygo\n"; contents["mylib-1.0.0.dist-info/METADATA"] != expected {
+		t.Fatalf("match should be %q, was %q", expected, contents["mylib-1.0.0.dist-info/METADATA"])
+	}
+	if wheel := contents["mylib-1.0.0.dist-info/WHEEL"]; !strings.Contains(wheel, "Root-Is-Purelib: false\nTag: py3-none-linux_x86_64\n") {
+		t.Fatalf("match should contain the tag, was %q", wheel)
+	}
+
+	// RECORD lists the hash and the size of the files, but its own
+	sum := sha256.Sum256([]byte("print()\n"))
+	line := fmt.Sprintf("mylib/lib.py,sha256=%s,8\n", base64.RawURLEncoding.EncodeToString(sum[:]))
+	record := contents["mylib-1.0.0.dist-info/RECORD"]
+	if !strings.Contains(record, line) || !strings.HasSuffix(record, "mylib-1.0.0.dist-info/RECORD,,\n") {
+		t.Fatalf("match should contain %q, was %q", line, record)
+	}
+
+	// the wheels are reproducible
+	if err := writeWheel(path, files, data); err != nil {
+		t.Fatalf("%v", err)
+	}
+	again, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(content, again) {
+		t.Fatalf("match should be %v, was %v", true, false)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("match should be %v, was %v", true, false)
+	}
+}